@@ -0,0 +1,47 @@
+package apierrors
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// 让校验错误中的字段名使用 json 标签，与请求体保持一致
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+			if name == "-" {
+				return ""
+			}
+			if name == "" {
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// Binding 将 gin 的绑定/校验错误转为带字段明细的 INVALID_INPUT
+func Binding(err error) *Error {
+	e := ErrInvalidInput.Wrap(err)
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fe := range validationErrs {
+			e.Details = append(e.Details, FieldError{Field: fe.Field(), Rule: fe.Tag(), Param: fe.Param()})
+		}
+	case errors.As(err, &typeErr):
+		e.Details = []FieldError{{Field: typeErr.Field, Rule: "type", Param: typeErr.Type.String()}}
+	case errors.As(err, &syntaxErr):
+		e.Details = []FieldError{{Rule: "json"}}
+	}
+	return e
+}
@@ -0,0 +1,175 @@
+package apierrors
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/i18n"
+	"github.com/yurin-kami/PackChann/utils"
+)
+
+// Code 稳定的机器可读错误码，前端据此做本地化展示
+type Code string
+
+const (
+	CodeInvalidInput          Code = "INVALID_INPUT"
+	CodeUnauthorized          Code = "UNAUTHORIZED"
+	CodeTokenInvalid          Code = "TOKEN_INVALID"
+	CodeForbidden             Code = "FORBIDDEN"
+	CodeInvalidCredentials    Code = "INVALID_CREDENTIALS"
+	CodeUserNotFound          Code = "USER_NOT_FOUND"
+	CodeUserAlreadyExists     Code = "USER_ALREADY_EXISTS"
+	CodeUserHasUncollected    Code = "USER_HAS_UNCOLLECTED_PACKS"
+	CodeUserAnonymized        Code = "USER_ANONYMIZED"
+	CodeCollectPacksFirst     Code = "COLLECT_PACKS_FIRST"
+	CodePackNotFound          Code = "PACK_NOT_FOUND"
+	CodePackAlreadyCheckedIn  Code = "PACK_ALREADY_CHECKED_IN"
+	CodePackInvalidTransition Code = "PACK_INVALID_TRANSITION"
+	CodeManifestNotFound      Code = "RETURN_MANIFEST_NOT_FOUND"
+	CodeManifestEmpty         Code = "RETURN_MANIFEST_EMPTY"
+	CodeManifestClosed        Code = "RETURN_MANIFEST_CLOSED"
+	CodeEvidenceInvalid       Code = "EVIDENCE_INVALID"
+	CodeStorageFeeNotDue      Code = "STORAGE_FEE_NOT_DUE"
+	CodePasswordTooWeak       Code = "PASSWORD_TOO_WEAK"
+	CodePasswordMismatch      Code = "PASSWORD_MISMATCH"
+	CodeResetCodeInvalid      Code = "RESET_CODE_INVALID"
+	CodeResetUnavailable      Code = "PASSWORD_RESET_UNAVAILABLE"
+	CodeMFATokenInvalid       Code = "MFA_TOKEN_INVALID"
+	CodeMFACodeInvalid        Code = "MFA_CODE_INVALID"
+	CodeMFARequired           Code = "MFA_REQUIRED"
+	CodeMFAAlreadyEnabled     Code = "MFA_ALREADY_ENABLED"
+	CodeMFANotEnrolled        Code = "MFA_NOT_ENROLLED"
+	CodeReauthRequired        Code = "REAUTH_REQUIRED"
+	CodeSSOProviderNotFound   Code = "SSO_PROVIDER_NOT_FOUND"
+	CodeSSOFailed             Code = "SSO_FAILED"
+	CodeSSONotLinked          Code = "SSO_NOT_LINKED"
+	CodeSSOProfileIncomplete  Code = "SSO_PROFILE_INCOMPLETE"
+	CodeAPIKeyInvalid         Code = "API_KEY_INVALID"
+	CodeAPIKeyIPNotAllowed    Code = "API_KEY_IP_NOT_ALLOWED"
+	CodeAPIKeyNotFound        Code = "API_KEY_NOT_FOUND"
+	CodeInsufficientScope     Code = "INSUFFICIENT_SCOPE"
+	CodeRateLimited           Code = "RATE_LIMITED"
+	CodeAccountLocked         Code = "ACCOUNT_LOCKED"
+	CodeInternal              Code = "INTERNAL_ERROR"
+)
+
+var (
+	ErrInvalidInput          = New(http.StatusBadRequest, CodeInvalidInput, "Invalid input")
+	ErrUnauthorized          = New(http.StatusUnauthorized, CodeUnauthorized, "Authorization header is required")
+	ErrTokenInvalid          = New(http.StatusUnauthorized, CodeTokenInvalid, "Invalid or expired token")
+	ErrForbidden             = New(http.StatusForbidden, CodeForbidden, "Admin access required")
+	ErrInvalidCredentials    = New(http.StatusUnauthorized, CodeInvalidCredentials, "Invalid credentials")
+	ErrUserNotFound          = New(http.StatusNotFound, CodeUserNotFound, "User not found")
+	ErrUserAlreadyExists     = New(http.StatusConflict, CodeUserAlreadyExists, "User already exists")
+	ErrUserHasUncollected    = New(http.StatusConflict, CodeUserHasUncollected, "User still has uncollected packs, transfer or return them first")
+	ErrUserAnonymized        = New(http.StatusConflict, CodeUserAnonymized, "User has been anonymized and cannot be restored")
+	ErrCollectPacksFirst     = New(http.StatusConflict, CodeCollectPacksFirst, "Collect your remaining packs before closing the account")
+	ErrPackNotFound          = New(http.StatusNotFound, CodePackNotFound, "Pack not found")
+	ErrPackAlreadyCheckedIn  = New(http.StatusConflict, CodePackAlreadyCheckedIn, "Pack already checked in")
+	ErrPackInvalidTransition = New(http.StatusConflict, CodePackInvalidTransition, "Pack status transition is not allowed")
+	ErrManifestNotFound      = New(http.StatusNotFound, CodeManifestNotFound, "Return manifest not found")
+	ErrManifestEmpty         = New(http.StatusConflict, CodeManifestEmpty, "No packs awaiting return for this manifest")
+	ErrManifestClosed        = New(http.StatusConflict, CodeManifestClosed, "Return manifest has already been handed over")
+	ErrEvidenceInvalid       = New(http.StatusBadRequest, CodeEvidenceInvalid, "Handover evidence must be a PNG, JPEG or WebP image within the size limit")
+	ErrStorageFeeNotDue      = New(http.StatusConflict, CodeStorageFeeNotDue, "No storage fee is due for this pack")
+	ErrPasswordTooWeak       = New(http.StatusBadRequest, CodePasswordTooWeak, "Password does not meet the strength policy")
+	ErrPasswordMismatch      = New(http.StatusBadRequest, CodePasswordMismatch, "Current password is incorrect")
+	ErrResetCodeInvalid      = New(http.StatusBadRequest, CodeResetCodeInvalid, "Verification code is invalid or expired")
+	ErrResetUnavailable      = New(http.StatusServiceUnavailable, CodeResetUnavailable, "Password reset is not available, please contact an administrator")
+	ErrMFATokenInvalid       = New(http.StatusUnauthorized, CodeMFATokenInvalid, "Two-factor session is invalid or expired, please sign in again")
+	ErrMFACodeInvalid        = New(http.StatusBadRequest, CodeMFACodeInvalid, "Invalid two-factor code")
+	ErrMFARequired           = New(http.StatusForbidden, CodeMFARequired, "Two-factor authentication is required for this account")
+	ErrMFAAlreadyEnabled     = New(http.StatusConflict, CodeMFAAlreadyEnabled, "Two-factor authentication is already enabled")
+	ErrMFANotEnrolled        = New(http.StatusConflict, CodeMFANotEnrolled, "Start two-factor enrollment first")
+	ErrReauthRequired        = New(http.StatusForbidden, CodeReauthRequired, "Please sign in again to confirm this action")
+	ErrSSOProviderNotFound   = New(http.StatusNotFound, CodeSSOProviderNotFound, "Identity provider not found")
+	ErrSSOFailed             = New(http.StatusUnauthorized, CodeSSOFailed, "Single sign-on failed, please try again")
+	ErrSSONotLinked          = New(http.StatusForbidden, CodeSSONotLinked, "No local account is linked to this identity")
+	ErrSSOProfileIncomplete  = New(http.StatusBadRequest, CodeSSOProfileIncomplete, "Identity provider did not supply the student ID or phone number")
+	ErrAPIKeyInvalid         = New(http.StatusUnauthorized, CodeAPIKeyInvalid, "API key is invalid, expired or revoked")
+	ErrAPIKeyIPNotAllowed    = New(http.StatusForbidden, CodeAPIKeyIPNotAllowed, "API key is not allowed from this address")
+	ErrAPIKeyNotFound        = New(http.StatusNotFound, CodeAPIKeyNotFound, "API key not found")
+	ErrInsufficientScope     = New(http.StatusForbidden, CodeInsufficientScope, "API key does not have the required scope")
+	ErrRateLimited           = New(http.StatusTooManyRequests, CodeRateLimited, "Too many requests, please try again later")
+	ErrAccountLocked         = New(http.StatusLocked, CodeAccountLocked, "Account is temporarily locked after too many failed sign-in attempts")
+	ErrInternal              = New(http.StatusInternalServerError, CodeInternal, "Internal server error")
+)
+
+// FieldError 单个字段的校验失败信息
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+// Error API 错误，Status 决定 HTTP 状态码，Cause 只写入日志不返回给客户端。
+// Message 为英文兜底文案，响应时会按请求语言从消息目录中取对应翻译
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	Details []FieldError
+	Cause   error
+}
+
+// Response 统一的错误响应体
+type Response struct {
+	Code      Code         `json:"code"`
+	Message   string       `json:"message"`
+	Details   []FieldError `json:"details,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	if e.Cause != nil {
+		return string(e.Code) + ": " + e.Cause.Error()
+	}
+	return string(e.Code) + ": " + e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Cause
+}
+
+// Wrap 返回附带底层原因的副本，预定义错误本身不会被修改
+func (e *Error) Wrap(cause error) *Error {
+	cp := *e
+	cp.Cause = cause
+	return &cp
+}
+
+// WithDetails 返回附带字段明细的副本
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	cp := *e
+	cp.Details = details
+	return &cp
+}
+
+// Internal 将未知错误包装为 500，真实原因只记录在日志中
+func Internal(cause error) *Error {
+	return ErrInternal.Wrap(cause)
+}
+
+// Respond 输出统一错误响应并中止后续处理；5xx 错误会连同原因写入日志
+func Respond(c *gin.Context, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal(err)
+	}
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		utils.Logger(c).Error(apiErr.Message, "code", apiErr.Code, "error", apiErr.Cause, "status", apiErr.Status)
+	}
+
+	c.AbortWithStatusJSON(apiErr.Status, Response{
+		Code:      apiErr.Code,
+		Message:   i18n.T(i18n.Lang(c), string(apiErr.Code), apiErr.Message),
+		Details:   apiErr.Details,
+		RequestID: c.GetString("request_id"),
+	})
+}
@@ -0,0 +1,170 @@
+// Package audit 写入审计记录。调用方在执行变更的事务中调用 Record，变更回滚时审计记录一并回滚
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+)
+
+// Entry 一次操作。Before / After 为变更前后的对象，新建时 Before 为 nil，删除时 After 为 nil；
+// 按 JSON 序列化结果比较，json:"-" 的字段（密码哈希、密钥等）不会进入审计记录，personalFields 中的字段只记录是否变化
+type Entry struct {
+	Action     string
+	TargetType string
+	TargetId   any
+	Before     any
+	After      any
+}
+
+// Record 以当前请求的认证身份作为操作者写入审计记录
+func Record(c *gin.Context, tx *gorm.DB, e Entry) error {
+	log, err := build(e)
+	if err != nil {
+		return err
+	}
+
+	log.ActorType = models.ActorAnonymous
+	if id, ok := c.Get("api_key_id"); ok {
+		log.ActorType = models.ActorAPIKey
+		log.ActorId, _ = id.(int64)
+	} else if id := c.GetString("user_id"); id != "" {
+		log.ActorType = models.ActorUser
+		log.ActorId, _ = strconv.ParseInt(id, 10, 64)
+	}
+	log.IP = c.ClientIP()
+	log.UserAgent = truncate(c.Request.UserAgent(), 255)
+	log.RequestId = c.GetString("request_id")
+
+	return tx.Create(&log).Error
+}
+
+// RecordSystem 写入后台任务发起的操作，没有请求来源信息
+func RecordSystem(tx *gorm.DB, e Entry) error {
+	log, err := build(e)
+	if err != nil {
+		return err
+	}
+	log.ActorType = models.ActorSystem
+	return tx.Create(&log).Error
+}
+
+// personalFields 个人信息字段。audit_log 只能追加，注销匿名化时无法清除，这些字段的值替换为 redacted，
+// 仍然能看出哪些字段被修改。目标请使用用户 ID 等内部 ID，不要使用学号、手机号
+var personalFields = map[string]bool{
+	"user_name":        true,
+	"student_id":       true,
+	"phone":            true,
+	"address":          true,
+	"pickup_code":      true,
+	"tracking_number":  true,
+	"courier_name":     true,
+	"courier_phone":    true,
+	"recipient":        true,
+	"recipient_phone":  true,
+	"shipper_phone":    true,
+	"shipping_address": true,
+	"reciving_address": true,
+}
+
+const redacted = "[redacted]"
+
+func build(e Entry) (models.AuditLog, error) {
+	before, after, err := diff(e.Before, e.After)
+	if err != nil {
+		return models.AuditLog{}, fmt.Errorf("audit %s: %w", e.Action, err)
+	}
+	return models.AuditLog{
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetId:   fmt.Sprint(e.TargetId),
+		Before:     before,
+		After:      after,
+	}, nil
+}
+
+// diff 两边都存在时只保留值不同的字段，否则保留存在的一边的全部字段
+func diff(before, after any) (json.RawMessage, json.RawMessage, error) {
+	b, err := toMap(before)
+	if err != nil {
+		return nil, nil, err
+	}
+	a, err := toMap(after)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if b != nil && a != nil {
+		changedB, changedA := map[string]any{}, map[string]any{}
+		for k, v := range b {
+			if !reflect.DeepEqual(v, a[k]) {
+				changedB[k] = v
+			}
+		}
+		for k, v := range a {
+			if !reflect.DeepEqual(v, b[k]) {
+				changedA[k] = v
+			}
+		}
+		b, a = changedB, changedA
+	}
+
+	redact(b)
+	redact(a)
+	return marshal(b), marshal(a), nil
+}
+
+// redact 递归替换个人信息字段的值，嵌套对象（如 gin.H{"manifest": ...}）同样处理
+func redact(v any) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, field := range v {
+			if personalFields[k] {
+				if field != nil && field != "" {
+					v[k] = redacted
+				}
+				continue
+			}
+			redact(field)
+		}
+	case []any:
+		for _, item := range v {
+			redact(item)
+		}
+	}
+}
+
+func toMap(v any) (map[string]any, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]any
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func marshal(m map[string]any) json.RawMessage {
+	if m == nil {
+		return nil
+	}
+	data, _ := json.Marshal(m)
+	return data
+}
+
+func truncate(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+package audit
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/models"
+)
+
+func decode(t *testing.T, raw json.RawMessage) map[string]any {
+	t.Helper()
+	if raw == nil {
+		return nil
+	}
+	var m map[string]any
+	if err := json.Unmarshal(raw, &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestBuildRedactsPersonalFields(t *testing.T) {
+	user := models.User{UserId: 42, UserName: "张三", StudentId: "20210001", Phone: "13800000000", Address: "1 号楼", Role: "user", PasswordHash: "hash"}
+	log, err := build(Entry{Action: "user.delete", TargetType: "user", TargetId: user.UserId, Before: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := decode(t, log.Before)
+	for _, field := range []string{"user_name", "student_id", "phone", "address"} {
+		if before[field] != redacted {
+			t.Errorf("before[%s] = %v, want %q", field, before[field], redacted)
+		}
+	}
+	if before["user_id"] != float64(42) || before["role"] != "user" {
+		t.Errorf("before = %v, want user_id and role kept", before)
+	}
+	if _, ok := before["password_hash"]; ok {
+		t.Error("before contains password_hash")
+	}
+	if log.TargetId != "42" || log.After != nil {
+		t.Errorf("target_id = %q, after = %s", log.TargetId, log.After)
+	}
+}
+
+func TestBuildRedactsChangedAndNestedFields(t *testing.T) {
+	before := models.Pack{PackId: 1, UserId: 7, TrackingNumber: "SF100", ShelfCode: 3}
+	after := before
+	after.TrackingNumber = "SF200"
+	after.ShelfCode = 4
+
+	log, err := build(Entry{Action: "pack.update", TargetType: "pack", TargetId: 1, Before: before, After: after})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, a := decode(t, log.Before), decode(t, log.After)
+	// 修改了个人信息字段仍然能看出来，但看不到值
+	if b["tracking_number"] != redacted || a["tracking_number"] != redacted {
+		t.Errorf("tracking_number = %v -> %v, want redacted", b["tracking_number"], a["tracking_number"])
+	}
+	if b["shelf_code"] != float64(3) || a["shelf_code"] != float64(4) {
+		t.Errorf("shelf_code = %v -> %v, want 3 -> 4", b["shelf_code"], a["shelf_code"])
+	}
+	if _, ok := a["user_id"]; ok {
+		t.Error("unchanged user_id recorded")
+	}
+
+	manifest := models.ReturnManifest{ManifestId: 9, Carrier: "SF", CourierName: "李四", CourierPhone: "13900000000"}
+	log, err = build(Entry{Action: "return_manifest.create", TargetType: "return_manifest", TargetId: 9, After: gin.H{"manifest": manifest, "pack_ids": []int64{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	nested, _ := decode(t, log.After)["manifest"].(map[string]any)
+	if nested["courier_name"] != redacted || nested["courier_phone"] != redacted || nested["carrier"] != "SF" {
+		t.Errorf("manifest = %v, want courier fields redacted", nested)
+	}
+}
+
+func TestBuildKeepsEmptyPersonalFields(t *testing.T) {
+	log, err := build(Entry{Action: "pack.status", TargetType: "pack", TargetId: 1, After: models.Pack{PackId: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after := decode(t, log.After); after["pickup_code"] != "" {
+		t.Errorf("pickup_code = %v, want empty", after["pickup_code"])
+	}
+}
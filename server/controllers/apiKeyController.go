@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/utils"
+	"gorm.io/gorm"
+)
+
+// ListAPIKeys 列出全部 API Key（包括已吊销的），不返回密钥本身
+func ListAPIKeys(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		keys := []models.APIKey{}
+		if err := db.WithContext(ctx).Order("created_at DESC").Find(&keys).Error; err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"api_keys": keys})
+	}
+}
+
+// CreateAPIKey 创建 API Key，明文只在本次响应中返回
+func CreateAPIKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.CreateAPIKeyInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		raw, prefix, hash, err := utils.NewAPIKey()
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		createdBy, _ := strconv.ParseInt(c.GetString("user_id"), 10, 64)
+
+		key := models.APIKey{
+			Name:       input.Name,
+			Prefix:     prefix,
+			KeyHash:    hash,
+			Scopes:     input.Scopes,
+			AllowedIPs: input.AllowedIPs,
+			CreatedBy:  createdBy,
+			ExpiresAt:  input.ExpiresAt,
+		}
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&key).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "api_key.create", TargetType: "api_key", TargetId: key.KeyId, After: key})
+		})
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"api_key": key, "key": raw})
+	}
+}
+
+// RevokeAPIKey 吊销 API Key，立即失效
+func RevokeAPIKey(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var key models.APIKey
+			if err := tx.Where("key_id = ? AND revoked_at IS NULL", c.Param("key_id")).Take(&key).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return apierrors.ErrAPIKeyNotFound
+				}
+				return err
+			}
+			before := key
+			now := time.Now()
+			key.RevokedAt = &now
+			if err := tx.Model(&key).Update("revoked_at", now).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "api_key.revoke", TargetType: "api_key", TargetId: key.KeyId, Before: before, After: key})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "api key revoked"})
+	}
+}
@@ -0,0 +1,64 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+)
+
+var auditSortable = map[string]string{
+	"created_at": "created_at",
+	"action":     "action",
+}
+
+// GetAuditLogs 按操作者、目标、操作类型与时间范围查询审计记录（管理员权限）
+func GetAuditLogs(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q models.AuditLogQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		query := database.Reader(db).WithContext(ctx).Model(&models.AuditLog{})
+		if q.ActorType != "" {
+			query = query.Where("actor_type = ?", q.ActorType)
+		}
+		if q.ActorId != 0 {
+			query = query.Where("actor_id = ?", q.ActorId)
+		}
+		if actions := splitList(q.Action); len(actions) > 0 {
+			query = query.Where("action IN ?", actions)
+		}
+		if q.TargetType != "" {
+			query = query.Where("target_type = ?", q.TargetType)
+		}
+		if q.TargetId != "" {
+			query = query.Where("target_id = ?", q.TargetId)
+		}
+		if !q.Since.IsZero() {
+			query = query.Where("created_at >= ?", q.Since)
+		}
+		if !q.Until.IsZero() {
+			query = query.Where("created_at < ?", q.Until)
+		}
+
+		logs := []models.AuditLog{}
+		page, err := paginate(query, q.PageQuery, auditSortable, "-created_at", "audit_id", &logs)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"audit_logs": logs, "total": page.Total, "page": page.Page, "page_size": page.PageSize})
+	}
+}
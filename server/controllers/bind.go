@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindJSON 解析 JSON 请求体，并用同名路径参数（如 /api/v1/packs/:pack_id）覆盖对应 json 字段后再做校验，
+// 这样同一个处理函数既能服务旧的请求体传 ID 的路由，也能服务 v1 的资源路由
+func bindJSON(c *gin.Context, obj any) error {
+	if c.Request.Body != nil {
+		if err := json.NewDecoder(c.Request.Body).Decode(obj); err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+	}
+
+	if len(c.Params) > 0 {
+		if err := applyPathParams(c.Params, obj); err != nil {
+			return err
+		}
+	}
+
+	return binding.Validator.ValidateStruct(obj)
+}
+
+func applyPathParams(params gin.Params, obj any) error {
+	v := reflect.ValueOf(obj).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := strings.SplitN(t.Field(i).Tag.Get("json"), ",", 2)[0]
+		value, ok := params.Get(name)
+		if !ok || name == "" {
+			continue
+		}
+
+		field := v.Field(i)
+		switch field.Kind() {
+		case reflect.Int64:
+			n, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return &json.UnmarshalTypeError{Value: "string", Type: field.Type(), Field: name}
+			}
+			field.SetInt(n)
+		case reflect.String:
+			field.SetString(value)
+		}
+	}
+	return nil
+}
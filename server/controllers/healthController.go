@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/utils"
+	"github.com/yurin-kami/PackChann/workers"
+	"gorm.io/gorm"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// componentOf 探针是匿名接口，失败原因只写日志，响应中只返回 fail
+func componentOf(c *gin.Context, name string, err error) models.ComponentStatus {
+	if err != nil {
+		utils.Logger(c).Warn("就绪检查失败", "component", name, "error", err)
+		return models.ComponentStatus{Status: "fail"}
+	}
+	return models.ComponentStatus{Status: "ok"}
+}
+
+// Liveness 存活检查，只要进程能响应即返回 ok
+func Liveness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, models.HealthStatus{Status: "ok"})
+	}
+}
+
+// Readiness 就绪检查：服务未处于关闭流程、数据库可连通、迁移已完成、后台任务均在运行
+func Readiness(db *gorm.DB, manager *workers.Manager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, readinessTimeout)
+		defer cancel()
+
+		components := make(map[string]models.ComponentStatus)
+
+		var serverErr error
+		if !utils.IsReady() {
+			serverErr = errors.New("server is not accepting traffic")
+		}
+		components["server"] = componentOf(c, "server", serverErr)
+
+		dbErr := func() error {
+			sqlDB, err := db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		}()
+		components["database"] = componentOf(c, "database", dbErr)
+
+		var migrationErr error
+		if dbErr != nil {
+			migrationErr = errors.New("database unavailable")
+		} else {
+			migrationErr = database.CheckMigrations(ctx, db)
+		}
+		components["migrations"] = componentOf(c, "migrations", migrationErr)
+
+		var stopped []string
+		for name, running := range manager.Status() {
+			if !running {
+				stopped = append(stopped, name)
+			}
+		}
+		var workersErr error
+		if len(stopped) > 0 {
+			workersErr = fmt.Errorf("stopped: %s", strings.Join(stopped, ", "))
+		}
+		components["workers"] = componentOf(c, "workers", workersErr)
+
+		res := models.HealthStatus{Status: "ok", Components: components}
+		for _, component := range components {
+			if component.Status != "ok" {
+				res.Status = "fail"
+				c.JSON(http.StatusServiceUnavailable, res)
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
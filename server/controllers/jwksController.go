@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/keyring"
+)
+
+// JWKS 公布 Token 签名公钥（RFC 7517）。新密钥在开始签名前已提前公布，缓存时间远小于提前量
+func JWKS(keys *keyring.Keyring) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, keys.JWKS())
+	}
+}
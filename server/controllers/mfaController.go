@@ -0,0 +1,438 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/mfa"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/ratelimit"
+	"github.com/yurin-kami/PackChann/utils"
+	"gorm.io/gorm"
+)
+
+// findUser 按用户 ID 查询用户，不存在时返回 ErrUserNotFound
+func findUser(ctx context.Context, db *gorm.DB, userId string) (models.User, error) {
+	var user models.User
+	err := db.WithContext(ctx).Where("user_id = ?", userId).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return user, apierrors.ErrUserNotFound
+	}
+	return user, err
+}
+
+// selfUser 只允许操作自己的账号
+func selfUser(c *gin.Context, ctx context.Context, db *gorm.DB, userId string) (models.User, error) {
+	if c.GetString("user_id") != userId {
+		return models.User{}, apierrors.ErrForbidden
+	}
+	return findUser(ctx, db, userId)
+}
+
+// mfaTokenUser 从登录第一步签发的 mfa_token 取出用户
+func mfaTokenUser(ctx context.Context, db *gorm.DB, keys *keyring.Keyring, token string) (models.User, error) {
+	userId, err := utils.ParsePurposeToken(token, keys, utils.PurposeMFA)
+	if err != nil {
+		return models.User{}, apierrors.ErrMFATokenInvalid.Wrap(err)
+	}
+	user, err := findUser(ctx, db, userId)
+	if errors.Is(err, apierrors.ErrUserNotFound) {
+		return user, apierrors.ErrMFATokenInvalid
+	}
+	return user, err
+}
+
+// useTOTP 校验 TOTP 验证码并记录已使用的时间步；条件更新保证同一验证码并发提交时只有一次成功
+func useTOTP(ctx context.Context, db *gorm.DB, user models.User, code string) (bool, error) {
+	step, ok := mfa.Verify(user.TOTPSecret, code, user.TOTPLastStep, time.Now())
+	if !ok {
+		return false, nil
+	}
+	res := db.WithContext(ctx).Model(&models.User{}).
+		Where("user_id = ? AND totp_last_step < ?", user.UserId, step).
+		Update("totp_last_step", step)
+	return res.RowsAffected == 1, res.Error
+}
+
+// useMFACode 依次尝试 TOTP 验证码与恢复码，恢复码使用后即作废
+func useMFACode(ctx context.Context, db *gorm.DB, user models.User, code string) (bool, error) {
+	if ok, err := useTOTP(ctx, db, user, code); ok || err != nil {
+		return ok, err
+	}
+	res := db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", user.UserId, mfa.HashRecoveryCode(code)).
+		Update("used_at", time.Now())
+	return res.RowsAffected == 1, res.Error
+}
+
+// codeError 把验证码的校验结果转换为响应错误
+func codeError(ok bool, err error) error {
+	if err != nil {
+		return apierrors.Internal(err)
+	}
+	if !ok {
+		return apierrors.ErrMFACodeInvalid
+	}
+	return nil
+}
+
+// guardMFA 校验验证码。验证码错误与密码错误共用失败计数和锁定：锁定期间直接拒绝，
+// verify 返回 ErrMFACodeInvalid 时计入失败次数，成功后清零
+func guardMFA(c *gin.Context, ctx context.Context, db *gorm.DB, limiter *ratelimit.Limiter, user models.User, verify func() error) error {
+	wait, err := limiter.LockedFor(ctx, user.StudentId)
+	if err != nil {
+		utils.Logger(c).Warn("rate limit store unavailable", "error", err)
+	}
+	if wait > 0 {
+		c.Header("Retry-After", ratelimit.RetryAfter(wait))
+		return apierrors.ErrAccountLocked
+	}
+
+	if err := verify(); err != nil {
+		if errors.Is(err, apierrors.ErrMFACodeInvalid) {
+			recordLoginFailure(c, db, limiter, user.StudentId)
+		}
+		return err
+	}
+	if err := limiter.Reset(ctx, user.StudentId); err != nil {
+		utils.Logger(c).Warn("rate limit store unavailable", "error", err)
+	}
+	return nil
+}
+
+// replaceRecoveryCodes 作废旧恢复码并生成一组新的，明文只在本次响应中返回
+func replaceRecoveryCodes(tx *gorm.DB, userId int64, n int) ([]string, error) {
+	codes, hashes, err := mfa.NewRecoveryCodes(n)
+	if err != nil {
+		return nil, err
+	}
+	if err := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	rows := make([]models.RecoveryCode, len(hashes))
+	for i, h := range hashes {
+		rows[i] = models.RecoveryCode{UserId: userId, CodeHash: h}
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// startEnrollment 生成新的 TOTP 密钥，验证通过前不生效
+func startEnrollment(ctx context.Context, db *gorm.DB, user models.User, mfaCfg models.MFAConfig) (*mfa.Enrollment, error) {
+	if user.TOTPEnabled {
+		return nil, apierrors.ErrMFAAlreadyEnabled
+	}
+	enrollment, err := mfa.NewEnrollment(mfaCfg.Issuer, user.StudentId)
+	if err != nil {
+		return nil, err
+	}
+	err = db.WithContext(ctx).Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    enrollment.Secret,
+		"totp_last_step": 0,
+	}).Error
+	return enrollment, err
+}
+
+// finishEnrollment 用验证器上的第一个验证码确认绑定，启用两步验证并生成恢复码
+func finishEnrollment(ctx context.Context, db *gorm.DB, user models.User, code string, mfaCfg models.MFAConfig) ([]string, error) {
+	if user.TOTPEnabled {
+		return nil, apierrors.ErrMFAAlreadyEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, apierrors.ErrMFANotEnrolled
+	}
+
+	var codes []string
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 与登录相同的条件更新，同一验证码并发提交时只有一次能完成绑定
+		if err := codeError(useTOTP(ctx, tx, user, code)); err != nil {
+			return err
+		}
+		if err := tx.Model(&user).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.UserId, mfaCfg.RecoveryCodes)
+		return err
+	})
+	return codes, err
+}
+
+// LoginMFA 登录第二步：校验 mfa_token 与 TOTP 验证码（或恢复码）后发放 Token
+func LoginMFA(db *gorm.DB, keys *keyring.Keyring, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.MFALoginInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := mfaTokenUser(ctx, db, keys, input.MFAToken)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if !user.TOTPEnabled {
+			apierrors.Respond(c, apierrors.ErrMFANotEnrolled)
+			return
+		}
+
+		err = guardMFA(c, ctx, db, limiter, user, func() error {
+			return codeError(useMFACode(ctx, db, user, input.Code))
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		respondWithTokens(c, ctx, db, user, keys, nil)
+	}
+}
+
+// EnrollMFAWithToken 强制两步验证的角色首次登录时，凭 mfa_token 开始绑定验证器
+func EnrollMFAWithToken(db *gorm.DB, keys *keyring.Keyring, mfaCfg models.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.MFATokenInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := mfaTokenUser(ctx, db, keys, input.MFAToken)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		enrollment, err := startEnrollment(ctx, db, user, mfaCfg)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// ActivateMFAWithToken 完成首次登录时的绑定，返回恢复码并发放 Token
+func ActivateMFAWithToken(db *gorm.DB, keys *keyring.Keyring, mfaCfg models.MFAConfig, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.MFALoginInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := mfaTokenUser(ctx, db, keys, input.MFAToken)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		var codes []string
+		err = guardMFA(c, ctx, db, limiter, user, func() error {
+			codes, err = finishEnrollment(ctx, db, user, input.Code, mfaCfg)
+			return err
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		user.TOTPEnabled = true
+
+		respondWithTokens(c, ctx, db, user, keys, gin.H{"recovery_codes": codes})
+	}
+}
+
+// EnrollMFA 已登录用户开始绑定验证器，返回密钥、otpauth 链接与二维码
+func EnrollMFA(db *gorm.DB, mfaCfg models.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		enrollment, err := startEnrollment(ctx, db, user, mfaCfg)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, enrollment)
+	}
+}
+
+// ActivateMFA 用第一个验证码确认绑定，返回恢复码（只展示这一次）
+func ActivateMFA(db *gorm.DB, mfaCfg models.MFAConfig, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.MFACodeInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		var codes []string
+		err = guardMFA(c, ctx, db, limiter, user, func() error {
+			codes, err = finishEnrollment(ctx, db, user, input.Code, mfaCfg)
+			return err
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// DisableMFA 关闭两步验证，需提供验证码或恢复码；角色强制要求时不允许关闭
+func DisableMFA(db *gorm.DB, mfaCfg models.MFAConfig, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.MFACodeInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if mfaCfg.Requires(user.Role) {
+			apierrors.Respond(c, apierrors.ErrMFARequired)
+			return
+		}
+		if !user.TOTPEnabled {
+			apierrors.Respond(c, apierrors.ErrMFANotEnrolled)
+			return
+		}
+		err = guardMFA(c, ctx, db, limiter, user, func() error {
+			return codeError(useMFACode(ctx, db, user, input.Code))
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		if err := clearMFA(c, ctx, db, user, "mfa.disable"); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication disabled"})
+	}
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，旧恢复码全部作废
+func RegenerateRecoveryCodes(db *gorm.DB, mfaCfg models.MFAConfig, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.MFACodeInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if !user.TOTPEnabled {
+			apierrors.Respond(c, apierrors.ErrMFANotEnrolled)
+			return
+		}
+		err = guardMFA(c, ctx, db, limiter, user, func() error {
+			return codeError(useTOTP(ctx, db, user, input.Code))
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		var codes []string
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var err error
+			codes, err = replaceRecoveryCodes(tx, user.UserId, mfaCfg.RecoveryCodes)
+			return err
+		})
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// clearMFA 清除用户的 TOTP 密钥与恢复码，action 区分用户自行关闭与管理员重置
+func clearMFA(c *gin.Context, ctx context.Context, db *gorm.DB, user models.User, action string) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.User{}).Where("user_id = ?", user.UserId).Updates(map[string]interface{}{
+			"totp_secret":    "",
+			"totp_enabled":   false,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", user.UserId).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return audit.Record(c, tx, audit.Entry{
+			Action: action, TargetType: "user", TargetId: user.UserId,
+			Before: gin.H{"totp_enabled": user.TOTPEnabled}, After: gin.H{"totp_enabled": false},
+		})
+	})
+}
+
+// AdminResetMFA 用户丢失验证器和恢复码时由管理员重置两步验证（管理员权限）
+func AdminResetMFA(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := findUser(ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if err := clearMFA(c, ctx, db, user, "mfa.reset"); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "two-factor authentication reset"})
+	}
+}
@@ -4,19 +4,25 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/database"
 	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
 	"github.com/yurin-kami/PackChann/utils"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func CheckInPack(db *gorm.DB) gin.HandlerFunc {
+func CheckInPack(db *gorm.DB, notifier *notify.Notifier) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var checkInData models.CheckInPak
-		if err := c.ShouldBindJSON(&checkInData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		if err := bindJSON(c, &checkInData); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 
@@ -26,54 +32,90 @@ func CheckInPack(db *gorm.DB) gin.HandlerFunc {
 		var existPack models.Pack
 		err := db.WithContext(ctx).Where("pack_id = ? AND pack_status = ?", checkInData.PackId, "pending").First(&existPack).Error
 		if err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "Pack already checked in"})
+			apierrors.Respond(c, apierrors.ErrPackAlreadyCheckedIn)
+			return
+		}
+		if err != gorm.ErrRecordNotFound {
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
+		if checkInData.Size == "" {
+			checkInData.Size = models.PackSizeMedium
+		}
 		newPack := models.Pack{
-			PackId:      checkInData.PackId,
-			UserId:      checkInData.UserId,
-			PackStatus:  "pending",
-			PickupCode:  fmt.Sprint(checkInData.ShelfCode) + "-" + fmt.Sprint(time.Now().UnixNano())[:4], //1-1-1978
-			CheckInTime: time.Now(),
+			PackId:         checkInData.PackId,
+			UserId:         checkInData.UserId,
+			PackStatus:     "pending",
+			PickupCode:     fmt.Sprint(checkInData.ShelfCode) + "-" + fmt.Sprint(time.Now().UnixNano())[:4], //1-1-1978
+			ShelfCode:      checkInData.ShelfCode,
+			Carrier:        checkInData.Carrier,
+			TrackingNumber: checkInData.TrackingNumber,
+			Size:           checkInData.Size,
+			CheckInTime:    time.Now(),
 		}
 		err = db.WithContext(ctx).Create(&newPack).Error
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check in pack"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Pack checked in successfully", "pack": newPack})
+		// 通知收件人取件，失败不影响入库结果
+		var recipient models.User
+		if err := db.WithContext(ctx).Where("user_id = ?", newPack.UserId).First(&recipient).Error; err == nil {
+			err = notifier.Notify(ctx, recipient, notify.Message{
+				Code:   notify.NoticePackCheckedIn,
+				Params: map[string]any{"PackId": newPack.PackId, "PickupCode": newPack.PickupCode},
+			})
+			if err != nil {
+				utils.Logger(c).Warn("failed to notify recipient", "pack_id", newPack.PackId, "error", err)
+			}
+		}
 
+		c.JSON(http.StatusOK, gin.H{"message": "Pack checked in successfully", "pack": newPack})
 	}
 }
 
-func CheckOutPack(db *gorm.DB) gin.HandlerFunc {
+// CheckOutPack 取件出库。启用保管费时按当前策略在同一事务中记一笔收费，storage_fee 为这笔流水，未启用时为 null
+func CheckOutPack(db *gorm.DB, feeCfg models.StorageFeeConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var checkOutData models.CheckOutPak
-		if err := c.ShouldBindJSON(&checkOutData); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		if err := bindJSON(c, &checkOutData); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
 		var pendingPack models.Pack
-		err := db.WithContext(ctx).Where("pack_id = ? AND user_id = ? AND pack_status = ?", checkOutData.PackId, checkOutData.UserId, "pending").First(&pendingPack).Error
-		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "No pending pack found for checkout"})
-			return
-		}
+		var fee *models.StorageFee
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("pack_id = ? AND user_id = ? AND pack_status = ?", checkOutData.PackId, checkOutData.UserId, "pending").
+				First(&pendingPack).Error
+			if err == gorm.ErrRecordNotFound {
+				return apierrors.ErrPackNotFound
+			}
+			if err != nil {
+				return err
+			}
 
-		pendingPack.PackStatus = "checked_out"
-		pendingPack.CheckOutTime = time.Now()
-		err = db.WithContext(ctx).Save(&pendingPack).Error
+			pendingPack.PackStatus = "checked_out"
+			pendingPack.CheckOutTime = time.Now()
+			if err := tx.Save(&pendingPack).Error; err != nil {
+				return err
+			}
+			if feeCfg.Enabled {
+				fee, err = chargeStorageFee(tx, feeCfg, pendingPack, pendingPack.CheckOutTime)
+			}
+			return err
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check out pack"})
+			apierrors.Respond(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Pack checked out successfully", "pack": pendingPack})
+		c.JSON(http.StatusOK, gin.H{"message": "Pack checked out successfully", "pack": pendingPack, "storage_fee": fee})
 	}
 }
 
@@ -84,10 +126,7 @@ func GetAllPacksByUserId(db *gorm.DB) gin.HandlerFunc {
 
 		var packs []models.Pack
 		if err := db.WithContext(ctx).Where("user_id = ?", c.Param("user_id")).Find(&packs).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Pack Not found"})
-			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -103,10 +142,10 @@ func GetPackDetailsByPackId(db *gorm.DB) gin.HandlerFunc {
 		var pack models.Pack
 		if err := db.WithContext(ctx).Where("pack_id = ?", c.Param("pack_id")).First(&pack).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "pack not found"})
+				apierrors.Respond(c, apierrors.ErrPackNotFound)
 				return
 			}
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -117,8 +156,8 @@ func GetPackDetailsByPackId(db *gorm.DB) gin.HandlerFunc {
 func CancelMailPack(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var cancelMailPack models.CheckOutPak
-		if err := c.ShouldBindJSON(&cancelMailPack); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "input Invalid"})
+		if err := bindJSON(c, &cancelMailPack); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 
@@ -128,15 +167,16 @@ func CancelMailPack(db *gorm.DB) gin.HandlerFunc {
 		var pack models.Pack
 		if err := db.WithContext(ctx).Where("pack_id = ? AND user_id = ? AND pack_status = ?", cancelMailPack.PackId, cancelMailPack.UserId, "in_transit").First(&pack).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "pack not found"})
+				apierrors.Respond(c, apierrors.ErrPackNotFound)
+				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
 		pack.PackStatus = "cancelled"
 		if err := db.WithContext(ctx).Save(&pack).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -147,8 +187,8 @@ func CancelMailPack(db *gorm.DB) gin.HandlerFunc {
 func MailPack(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var mailPack models.MailPack
-		if err := c.ShouldBindJSON(&mailPack); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		if err := bindJSON(c, &mailPack); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 
@@ -158,14 +198,18 @@ func MailPack(db *gorm.DB) gin.HandlerFunc {
 		var user models.User
 		err := db.WithContext(ctx).Where("phone = ?", mailPack.RecipientPhone).First(&user).Error
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrUserNotFound)
+				return
+			}
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
 		// 生成包裹 ID（雪花算法）
 		packId, err := utils.GenerateID()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate pack id"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -176,7 +220,7 @@ func MailPack(db *gorm.DB) gin.HandlerFunc {
 		}
 		err = db.WithContext(ctx).Create(&newPack).Error
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create mail pack"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -184,33 +228,44 @@ func MailPack(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
+// UpdatePackStatus 按 packTransitions 更新寄件状态。在事务中锁定包裹后检查流转，只修改状态相关的列，
+// 不会覆盖并发的退件、交接等操作
 func UpdatePackStatus(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var updatePackStatus models.UpdatePackStatus
-		if err := c.ShouldBindJSON(&updatePackStatus); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "input Invalid"})
+		if err := bindJSON(c, &updatePackStatus); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		if !models.IsValidPackStatus(updatePackStatus.PackStatus) {
+			apierrors.Respond(c, apierrors.ErrInvalidInput.WithDetails(apierrors.FieldError{Field: "pack_status", Rule: "oneof"}))
 			return
 		}
 
-		ctx, candel := context.WithTimeout(c, 30*time.Second)
-		defer candel()
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
 
 		var pack models.Pack
-		if err := db.WithContext(ctx).Where("pack_id = ?", updatePackStatus.PackId).First(&pack).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "pack not found"})
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lockPack(tx, fmt.Sprint(updatePackStatus.PackId), &pack); err != nil {
+				return err
+			}
+			if !models.CanTransition(pack.PackStatus, updatePackStatus.PackStatus) {
+				return apierrors.ErrPackInvalidTransition
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			return
-		}
-
-		pack.PackStatus = updatePackStatus.PackStatus
-		if pack.PackStatus == "shipped" {
-			pack.CheckOutTime = time.Now()
-		}
 
-		if err := db.WithContext(ctx).Save(&pack).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			before := pack
+			updates := map[string]any{"pack_status": updatePackStatus.PackStatus}
+			if updatePackStatus.PackStatus == models.PackStatusShipped {
+				updates["check_out_time"] = time.Now()
+			}
+			if err := tx.Model(&pack).Updates(updates).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "pack.status", TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
 			return
 		}
 
@@ -218,26 +273,64 @@ func UpdatePackStatus(db *gorm.DB) gin.HandlerFunc {
 	}
 }
 
-// GetAllPacks 获取所有包裹（管理员权限），支持按状态筛选
-func GetAllPacks(db *gorm.DB) gin.HandlerFunc {
+// packSortable 包裹列表允许排序的字段
+var packSortable = map[string]string{
+	"check_in_time":  "check_in_time",
+	"check_out_time": "check_out_time",
+	"pack_status":    "pack_status",
+	"shelf_code":     "shelf_code",
+	"user_id":        "user_id",
+}
+
+// GetAllPacks 获取所有包裹（管理员权限），支持分页、排序与按状态/用户/快递公司/货架/取件码前缀/入库时间/逾期级别筛选
+func GetAllPacks(db *gorm.DB, overdueCfg models.OverdueConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		status := c.Query("status")
-		var packs []models.Pack
+		var q models.PackListQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
 
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		query := db.WithContext(ctx)
-		if status != "" {
-			query = query.Where("pack_status = ?", status)
+		query := database.Reader(db).WithContext(ctx).Model(&models.Pack{})
+		if q.Deleted {
+			query = query.Unscoped().Where("deleted_at IS NOT NULL")
+		}
+		if statuses := splitList(q.Status); len(statuses) > 0 {
+			query = query.Where("pack_status IN ?", statuses)
+		}
+		if q.UserId != 0 {
+			query = query.Where("user_id = ?", q.UserId)
+		}
+		if q.Carrier != "" {
+			query = query.Where("carrier = ?", q.Carrier)
+		}
+		if q.ShelfCode != 0 {
+			query = query.Where("shelf_code = ?", q.ShelfCode)
+		}
+		if q.PickupCodePrefix != "" {
+			query = query.Where("pickup_code LIKE ?", escapeLike(q.PickupCodePrefix)+"%")
+		}
+		if !q.CheckInFrom.IsZero() {
+			query = query.Where("check_in_time >= ?", q.CheckInFrom)
+		}
+		if !q.CheckInTo.IsZero() {
+			query = query.Where("check_in_time < ?", q.CheckInTo)
+		}
+		if levels := splitList(q.Overdue); len(levels) > 0 {
+			query = overdueScope(query, overdueCfg, levels, time.Now())
 		}
 
-		if err := query.Find(&packs).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		var packs []models.Pack
+		page, err := paginate(query, q.PageQuery, packSortable, "-check_in_time", "pack_id", &packs)
+		if err != nil {
+			apierrors.Respond(c, err)
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"packs": packs})
+		c.JSON(http.StatusOK, gin.H{"packs": packs, "total": page.Total, "page": page.Page, "page_size": page.PageSize})
 	}
 }
 
@@ -245,21 +338,16 @@ func GetAllPacks(db *gorm.DB) gin.HandlerFunc {
 func AdminUpdatePack(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input models.AdminUpdatePackInput
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		var pack models.Pack
-		if err := db.WithContext(ctx).Where("pack_id = ?", input.PackId).First(&pack).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "Pack not found"})
-			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
-			}
+		if input.PackStatus != nil && !models.IsValidPackStatus(*input.PackStatus) {
+			apierrors.Respond(c, apierrors.ErrInvalidInput.WithDetails(apierrors.FieldError{Field: "pack_status", Rule: "oneof"}))
 			return
 		}
 
@@ -280,13 +368,188 @@ func AdminUpdatePack(db *gorm.DB) gin.HandlerFunc {
 			updates["check_out_time"] = *input.CheckOutTime
 		}
 
-		if len(updates) > 0 {
-			if err := db.WithContext(ctx).Model(&pack).Updates(updates).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update pack"})
-				return
+		var pack models.Pack
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lockPack(tx, fmt.Sprint(input.PackId), &pack); err != nil {
+				return err
+			}
+			// 取件需要结算保管费，退件状态由退件流程维护，手动修改会绕过收费、交接单和通知
+			if input.PackStatus != nil && *input.PackStatus != pack.PackStatus && (isManagedStatus(*input.PackStatus) || isManagedStatus(pack.PackStatus)) {
+				return apierrors.ErrPackInvalidTransition
+			}
+			if len(updates) == 0 {
+				return nil
+			}
+
+			before := pack
+			if err := tx.Model(&pack).Updates(updates).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "pack.update", TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"pack": pack})
+	}
+}
+
+// isManagedStatus 只能通过专门的流程进入或离开的状态：取件和退件
+func isManagedStatus(status string) bool {
+	return status == models.PackStatusCheckedOut || status == models.PackStatusReturnPending || status == models.PackStatusReturned
+}
+
+// DeletePack 软删除包裹，用于撤销错误的入库记录（管理员权限）
+func DeletePack(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var pack models.Pack
+			if err := tx.Where("pack_id = ?", c.Param("pack_id")).First(&pack).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return apierrors.ErrPackNotFound
+				}
+				return err
+			}
+			if err := tx.Delete(&pack).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "pack.delete", TargetType: "pack", TargetId: pack.PackId, Before: pack})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "delete pack complete"})
+	}
+}
+
+// RestorePack 恢复已删除的包裹（管理员权限）
+func RestorePack(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var pack models.Pack
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := tx.Unscoped().Where("pack_id = ? AND deleted_at IS NOT NULL", c.Param("pack_id")).First(&pack).Error
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return apierrors.ErrPackNotFound
+				}
+				return err
+			}
+			before := pack
+			if err := tx.Unscoped().Model(&pack).Update("deleted_at", nil).Error; err != nil {
+				return err
 			}
+			pack.DeletedAt = gorm.DeletedAt{}
+			return audit.Record(c, tx, audit.Entry{Action: "pack.restore", TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{"pack": pack})
 	}
 }
+
+// overdueScope 只保留当前处于 levels 中任一逾期级别的待取包裹，按滞留时长实时计算，与逾期报表一致。
+// 级别都未启用时不返回任何包裹
+func overdueScope(query *gorm.DB, overdueCfg models.OverdueConfig, levels []string, now time.Time) *gorm.DB {
+	var conds []string
+	var args []any
+	for _, level := range levels {
+		before, notBefore, ok := overdueCfg.CheckInRange(level, now)
+		if !ok {
+			continue
+		}
+		if notBefore.IsZero() {
+			conds = append(conds, "check_in_time < ?")
+			args = append(args, before)
+		} else {
+			conds = append(conds, "(check_in_time < ? AND check_in_time >= ?)")
+			args = append(args, before, notBefore)
+		}
+	}
+	if len(conds) == 0 {
+		return query.Where("FALSE")
+	}
+	return query.Where("pack_status = ?", models.PackStatusPending).Where(strings.Join(conds, " OR "), args...)
+}
+
+// overdueSortable 逾期报表允许排序的字段
+var overdueSortable = map[string]string{
+	"check_in_time": "check_in_time",
+	"shelf_code":    "shelf_code",
+	"user_id":       "user_id",
+}
+
+// GetOverduePacks 逾期报表：按当前滞留时长列出超过提醒阈值的待取包裹，默认最久的在前、同时入库的按货架排列，
+// 并统计各级别数量（管理员权限）
+func GetOverduePacks(db *gorm.DB, overdueCfg models.OverdueConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q models.OverdueQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		now := time.Now()
+		levels := overdueCfg.Levels()
+		base := func() *gorm.DB {
+			query := database.Reader(db).WithContext(ctx).Model(&models.Pack{}).Where("pack_status = ?", models.PackStatusPending)
+			if q.ShelfCode != 0 {
+				query = query.Where("shelf_code = ?", q.ShelfCode)
+			}
+			return query
+		}
+
+		// 每个级别对应一段入库时间范围：达到本级阈值、未达到下一级阈值
+		summary := map[string]int64{}
+		var query *gorm.DB
+		for _, l := range levels {
+			level := overdueScope(base(), overdueCfg, []string{l.Level}, now)
+			var count int64
+			if err := level.Count(&count).Error; err != nil {
+				apierrors.Respond(c, apierrors.Internal(err))
+				return
+			}
+			summary[l.Level] = count
+			if l.Level == q.Level {
+				query = level
+			}
+		}
+		if query == nil {
+			if q.Level != "" || len(levels) == 0 {
+				c.JSON(http.StatusOK, gin.H{"packs": []models.OverduePack{}, "summary": summary, "total": 0, "page": 1, "page_size": defaultPageSize})
+				return
+			}
+			query = base().Where("check_in_time < ?", now.Add(-levels[0].After))
+		}
+
+		var packs []models.Pack
+		page, err := paginate(query, q.PageQuery, overdueSortable, "check_in_time,shelf_code", "pack_id", &packs)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		rows := make([]models.OverduePack, len(packs))
+		for i, p := range packs {
+			age := now.Sub(p.CheckInTime)
+			rows[i] = models.OverduePack{Pack: p, AgeHours: int64(age.Hours()), Level: overdueCfg.LevelOf(age)}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"packs": rows, "summary": summary, "total": page.Total, "page": page.Page, "page_size": page.PageSize})
+	}
+}
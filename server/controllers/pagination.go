@@ -0,0 +1,71 @@
+package controllers
+
+import (
+	"strings"
+
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const defaultPageSize = 20
+
+// paginate 统计总数并按排序、分页取出 dest。sortable 为允许排序的字段名到列名的映射，
+// 最后总会追加主键 tieBreaker，保证翻页时顺序稳定
+func paginate(query *gorm.DB, page models.PageQuery, sortable map[string]string, defaultSort, tieBreaker string, dest any) (models.Page, error) {
+	if page.Page == 0 {
+		page.Page = 1
+	}
+	if page.PageSize == 0 {
+		page.PageSize = defaultPageSize
+	}
+	if page.Sort == "" {
+		page.Sort = defaultSort
+	}
+
+	var orders []clause.OrderByColumn
+	for _, field := range strings.Split(page.Sort, ",") {
+		field = strings.TrimSpace(field)
+		desc := strings.HasPrefix(field, "-")
+		column, ok := sortable[strings.TrimPrefix(field, "-")]
+		if !ok {
+			return models.Page{}, apierrors.ErrInvalidInput.WithDetails(apierrors.FieldError{Field: "sort", Rule: "oneof", Param: field})
+		}
+		orders = append(orders, clause.OrderByColumn{Column: clause.Column{Name: column}, Desc: desc})
+	}
+	orders = append(orders, clause.OrderByColumn{Column: clause.Column{Name: tieBreaker}})
+
+	query = query.Session(&gorm.Session{})
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return models.Page{}, apierrors.Internal(err)
+	}
+
+	err := query.Order(clause.OrderBy{Columns: orders}).
+		Offset((page.Page - 1) * page.PageSize).
+		Limit(page.PageSize).
+		Find(dest).Error
+	if err != nil {
+		return models.Page{}, apierrors.Internal(err)
+	}
+
+	return models.Page{Total: total, Page: page.Page, PageSize: page.PageSize}, nil
+}
+
+// splitList 拆分逗号分隔的查询参数并去掉空项
+func splitList(s string) []string {
+	var res []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			res = append(res, item)
+		}
+	}
+	return res
+}
+
+// escapeLike 转义 LIKE 通配符，用于前缀匹配
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
+	"github.com/yurin-kami/PackChann/utils"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// bcrypt 只使用前 72 字节，超出部分会被静默忽略
+const maxPasswordBytes = 72
+
+// checkPasswordPolicy 按配置检查密码强度，不满足时返回逐条规则明细
+func checkPasswordPolicy(policy models.PasswordConfig, password string, user models.User) *apierrors.Error {
+	var details []apierrors.FieldError
+	fail := func(rule, param string) {
+		details = append(details, apierrors.FieldError{Field: "password", Rule: rule, Param: param})
+	}
+
+	if len([]rune(password)) < policy.MinLength {
+		fail("min", fmt.Sprint(policy.MinLength))
+	}
+	if len(password) > maxPasswordBytes {
+		fail("max", fmt.Sprint(maxPasswordBytes))
+	}
+
+	var letter, digit, symbol bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLetter(r):
+			letter = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	if policy.RequireLetter && !letter {
+		fail("letter", "")
+	}
+	if policy.RequireDigit && !digit {
+		fail("digit", "")
+	}
+	if policy.RequireSymbol && !symbol {
+		fail("symbol", "")
+	}
+
+	for _, personal := range []string{user.StudentId, user.Phone} {
+		if personal != "" && strings.Contains(password, personal) {
+			fail("personal_info", "")
+			break
+		}
+	}
+
+	if len(details) > 0 {
+		return apierrors.ErrPasswordTooWeak.WithDetails(details...)
+	}
+	return nil
+}
+
+// ChangePassword 用户修改自己的密码，需验证原密码；成功后吊销除当前会话外的所有 Token
+func ChangePassword(db *gorm.DB, policy models.PasswordConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.ChangePasswordInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		if c.GetString("user_id") != fmt.Sprint(input.UserId) {
+			apierrors.Respond(c, apierrors.ErrForbidden)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := db.WithContext(ctx).Where("user_id = ?", input.UserId).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrUserNotFound)
+				return
+			}
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		if !checkPassword(user.PasswordHash, input.OldPassword) {
+			apierrors.Respond(c, apierrors.ErrPasswordMismatch)
+			return
+		}
+		if err := checkPasswordPolicy(policy, input.NewPassword, user); err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		hash, err := passwordHash(input.NewPassword)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(&user).Update("password_hash", hash).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ? AND token_id <> ?", user.UserId, c.GetInt64("token_id")).Delete(&models.UserToken{}).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "password.change", TargetType: "user", TargetId: user.UserId})
+		})
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password changed"})
+	}
+}
+
+// generateResetCode 生成 6 位数字验证码
+func generateResetCode() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%06d", n.Int64()), nil
+}
+
+// ForgotPassword 生成找回密码验证码并通过 notifier 发送给用户。
+// 无论学号是否存在都返回相同响应并执行相同的哈希计算，避免通过响应内容或耗时探测账号；
+// 通知渠道不能送达用户（只写日志）时找回密码不可用
+func ForgotPassword(db *gorm.DB, notifier *notify.Notifier, policy models.PasswordConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.ForgotPasswordInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		if !notifier.Secure() {
+			apierrors.Respond(c, apierrors.ErrResetUnavailable)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var user models.User
+		err := db.WithContext(ctx).Where("student_id = ?", input.StudentId).First(&user).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		exists := err == nil
+
+		code, err := generateResetCode()
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		codeHash, err := bcrypt.GenerateFromPassword([]byte(code), bcrypt.DefaultCost)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		if exists {
+			// 保存与发送在后台进行，数据库写入与通知渠道的耗时不会体现在响应时间上
+			logger := utils.Logger(c)
+			bg := context.WithoutCancel(c.Request.Context())
+			go func() {
+				ctx, cancel := context.WithTimeout(bg, 30*time.Second)
+				defer cancel()
+				if err := issueResetCode(ctx, db, notifier, policy, user, code, string(codeHash)); err != nil {
+					logger.Error("send password reset code failed", "user_id", user.UserId, "error", err)
+				}
+			}()
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "if the account exists, a verification code has been sent"})
+	}
+}
+
+// issueResetCode 保存验证码哈希并发送给用户，新验证码生效时作废之前未使用的验证码
+func issueResetCode(ctx context.Context, db *gorm.DB, notifier *notify.Notifier, policy models.PasswordConfig, user models.User, code, codeHash string) error {
+	now := time.Now()
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordReset{}).
+			Where("user_id = ? AND used_at IS NULL", user.UserId).
+			Update("used_at", now).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.PasswordReset{
+			UserId:    user.UserId,
+			CodeHash:  codeHash,
+			ExpiresAt: now.Add(policy.ResetCodeTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return notifier.Notify(ctx, user, notify.Message{
+		Code:    notify.NoticePasswordResetCode,
+		Params:  map[string]any{"Code": code, "Minutes": int(policy.ResetCodeTTL.Minutes())},
+		Secrets: []string{"Code"},
+	})
+}
+
+// ResetPassword 校验找回密码验证码并设置新密码，成功后吊销该用户所有 Token
+func ResetPassword(db *gorm.DB, policy models.PasswordConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.ResetPasswordInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var user models.User
+		if err := db.WithContext(ctx).Where("student_id = ?", input.StudentId).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrResetCodeInvalid)
+				return
+			}
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		var reset models.PasswordReset
+		err := db.WithContext(ctx).
+			Where("user_id = ? AND used_at IS NULL AND expires_at > ? AND attempts < ?", user.UserId, time.Now(), policy.ResetMaxAttempts).
+			Order("created_at DESC").
+			First(&reset).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrResetCodeInvalid)
+				return
+			}
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		if bcrypt.CompareHashAndPassword([]byte(reset.CodeHash), []byte(input.Code)) != nil {
+			if err := db.WithContext(ctx).Model(&reset).Update("attempts", gorm.Expr("attempts + 1")).Error; err != nil {
+				apierrors.Respond(c, apierrors.Internal(err))
+				return
+			}
+			apierrors.Respond(c, apierrors.ErrResetCodeInvalid)
+			return
+		}
+
+		if err := checkPasswordPolicy(policy, input.NewPassword, user); err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		hash, err := passwordHash(input.NewPassword)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			// 条件更新保证并发提交同一验证码时只有一次成功
+			used := tx.Model(&reset).Where("used_at IS NULL").Update("used_at", time.Now())
+			if used.Error != nil {
+				return used.Error
+			}
+			if used.RowsAffected == 0 {
+				return apierrors.ErrResetCodeInvalid
+			}
+			if err := tx.Model(&user).Update("password_hash", hash).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.UserId).Delete(&models.UserToken{}).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "password.reset", TargetType: "user", TargetId: user.UserId})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "password reset complete"})
+	}
+}
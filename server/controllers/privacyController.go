@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/privacy"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ExportUserData 导出自己的全部个人数据，默认打包为 ZIP，format=json 时返回单个 JSON 文件
+func ExportUserData(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q models.ExportQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		archive, err := privacy.Export(ctx, db, user)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		if err := audit.Record(c, db.WithContext(ctx), audit.Entry{Action: "user.export", TargetType: "user", TargetId: user.UserId}); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		name := fmt.Sprintf("packchann-%d-%s", user.UserId, archive.ExportedAt.Format("20060102"))
+		if q.Format == "json" {
+			c.Header("Content-Disposition", `attachment; filename="`+name+`.json"`)
+			c.IndentedJSON(http.StatusOK, archive)
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := archive.WriteZip(&buf); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+name+`.zip"`)
+		c.Data(http.StatusOK, "application/zip", buf.Bytes())
+	}
+}
+
+// RequestDeletion 申请注销自己的账号，需要再次确认身份（见 confirmIdentity）。冷静期结束后个人信息被清除，之前可以撤销；
+// 已申请时直接返回原定的注销时间
+func RequestDeletion(db *gorm.DB, privacyCfg models.PrivacyConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.DeletionRequestInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, fmt.Sprint(input.UserId))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if err := confirmIdentity(c, ctx, db, user, input, privacyCfg.ReauthWindow); err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", user.UserId).First(&user).Error; err != nil {
+				return err
+			}
+			if user.DeletionDueAt != nil {
+				return nil
+			}
+			packs, err := uncollectedPacks(tx, user.UserId, false)
+			if err != nil {
+				return err
+			}
+			if len(packs) > 0 {
+				return apierrors.ErrCollectPacksFirst
+			}
+
+			before := user
+			due := time.Now().Add(privacyCfg.DeletionCoolingOff)
+			if err := tx.Model(&user).Update("deletion_due_at", due).Error; err != nil {
+				return err
+			}
+			user.DeletionDueAt = &due
+			return audit.Record(c, tx, audit.Entry{Action: "user.deletion_request", TargetType: "user", TargetId: user.UserId, Before: before, After: user})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "account deletion scheduled", "deletion_due_at": user.DeletionDueAt})
+	}
+}
+
+// confirmIdentity 注销前再次确认身份：有本地密码时校验密码；仅通过统一身份认证登录的账号没有密码，
+// 开启了两步验证时校验验证码或恢复码，否则要求当前会话在 window 内登录（重新通过统一身份认证登录）
+func confirmIdentity(c *gin.Context, ctx context.Context, db *gorm.DB, user models.User, input models.DeletionRequestInput, window time.Duration) error {
+	if user.PasswordHash != "" {
+		if !checkPassword(user.PasswordHash, input.Password) {
+			return apierrors.ErrPasswordMismatch
+		}
+		return nil
+	}
+
+	if user.TOTPEnabled {
+		ok, err := useMFACode(ctx, db, user, input.Code)
+		if err != nil {
+			return apierrors.Internal(err)
+		}
+		if !ok {
+			return apierrors.ErrMFACodeInvalid
+		}
+		return nil
+	}
+
+	var session models.UserToken
+	err := db.WithContext(ctx).Where("token_id = ? AND user_id = ?", c.GetInt64("token_id"), user.UserId).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return apierrors.ErrReauthRequired
+	} else if err != nil {
+		return apierrors.Internal(err)
+	}
+	if time.Since(session.CreatedAt) > window {
+		return apierrors.ErrReauthRequired
+	}
+	return nil
+}
+
+// CancelDeletion 冷静期内撤销注销申请，没有申请时什么也不做
+func CancelDeletion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := selfUser(c, ctx, db, c.Param("user_id"))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", user.UserId).First(&user).Error; err != nil {
+				return err
+			}
+			if user.DeletionDueAt == nil {
+				return nil
+			}
+
+			before := user
+			if err := tx.Model(&user).Update("deletion_due_at", nil).Error; err != nil {
+				return err
+			}
+			user.DeletionDueAt = nil
+			return audit.Record(c, tx, audit.Entry{Action: "user.deletion_cancel", TargetType: "user", TargetId: user.UserId, Before: before, After: user})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "account deletion cancelled"})
+	}
+}
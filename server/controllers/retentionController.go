@@ -0,0 +1,29 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/retention"
+	"gorm.io/gorm"
+)
+
+// RetentionReport 按当前保留规则试运行，返回每条规则现在会处理的记录数，不修改数据（管理员权限）
+func RetentionReport(db *gorm.DB, retentionCfg models.RetentionConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		results, err := retention.Run(ctx, db, retentionCfg, time.Now(), true)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"enabled": retentionCfg.Enabled, "dry_run": retentionCfg.DryRun, "results": results})
+	}
+}
@@ -0,0 +1,405 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
+	"github.com/yurin-kami/PackChann/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// evidenceTypes 允许上传的交接凭证格式及下载时使用的扩展名
+var evidenceTypes = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/webp": ".webp",
+}
+
+// MarkPackReturn 把待取包裹标记为待退回，包裹停止取件，等待加入交接单（管理员权限）
+func MarkPackReturn(db *gorm.DB, notifier *notify.Notifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var pack models.Pack
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lockPack(tx, c.Param("pack_id"), &pack); err != nil {
+				return err
+			}
+			if pack.PackStatus != models.PackStatusPending {
+				return apierrors.ErrPackInvalidTransition
+			}
+			before := pack
+			if err := tx.Model(&pack).Update("pack_status", models.PackStatusReturnPending).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "pack.return", TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		notifyReturn(c, ctx, db, notifier, []models.Pack{pack}, notify.NoticePackReturnPending, nil)
+		c.JSON(http.StatusOK, gin.H{"pack": pack})
+	}
+}
+
+// CancelPackReturn 撤销退回，包裹恢复为待取，原取件码继续有效。已加入交接单的包裹同时移出交接单（管理员权限）
+func CancelPackReturn(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var pack models.Pack
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := lockPack(tx, c.Param("pack_id"), &pack); err != nil {
+				return err
+			}
+			if pack.PackStatus != models.PackStatusReturnPending {
+				return apierrors.ErrPackInvalidTransition
+			}
+			before := pack
+			err := tx.Model(&pack).Updates(map[string]any{"pack_status": models.PackStatusPending, "return_manifest_id": nil}).Error
+			if err != nil {
+				return err
+			}
+			pack.ReturnManifestId = nil
+			return audit.Record(c, tx, audit.Entry{Action: "pack.return_cancel", TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"pack": pack})
+	}
+}
+
+// CreateReturnManifest 为一家快递公司创建交接单，未指定包裹时加入该公司全部尚未加入交接单的待退回包裹（管理员权限）
+func CreateReturnManifest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.CreateManifestInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		createdBy, _ := strconv.ParseInt(c.GetString("user_id"), 10, 64)
+		manifest := models.ReturnManifest{Carrier: input.Carrier, Status: models.ManifestOpen, CreatedBy: createdBy}
+		var packs []models.Pack
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			query := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("pack_status = ? AND carrier = ? AND return_manifest_id IS NULL", models.PackStatusReturnPending, input.Carrier)
+			if len(input.PackIds) > 0 {
+				query = query.Where("pack_id IN ?", input.PackIds)
+			}
+			if err := query.Order("pack_id").Find(&packs).Error; err != nil {
+				return err
+			}
+			// 指定的包裹必须全部可以退回，不静默跳过
+			if len(input.PackIds) > 0 && len(packs) != len(uniqueIds(input.PackIds)) {
+				return apierrors.ErrPackInvalidTransition
+			}
+			if len(packs) == 0 {
+				return apierrors.ErrManifestEmpty
+			}
+
+			if err := tx.Create(&manifest).Error; err != nil {
+				return err
+			}
+			ids := make([]int64, len(packs))
+			for i := range packs {
+				ids[i] = packs[i].PackId
+				packs[i].ReturnManifestId = &manifest.ManifestId
+			}
+			if err := tx.Model(&models.Pack{}).Where("pack_id IN ?", ids).Update("return_manifest_id", manifest.ManifestId).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{
+				Action:     "return_manifest.create",
+				TargetType: "return_manifest",
+				TargetId:   manifest.ManifestId,
+				After:      gin.H{"manifest": manifest, "pack_ids": ids},
+			})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"manifest": manifest, "packs": packs})
+	}
+}
+
+// manifestSortable 交接单列表允许排序的字段
+var manifestSortable = map[string]string{
+	"created_at":     "created_at",
+	"handed_over_at": "handed_over_at",
+	"carrier":        "carrier",
+}
+
+// GetReturnManifests 分页列出交接单，可按状态与快递公司筛选（管理员权限）
+func GetReturnManifests(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q models.ManifestListQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		query := database.Reader(db).WithContext(ctx).Model(&models.ReturnManifest{}).Omit("evidence")
+		if q.Status != "" {
+			query = query.Where("status = ?", q.Status)
+		}
+		if q.Carrier != "" {
+			query = query.Where("carrier = ?", q.Carrier)
+		}
+
+		var manifests []models.ReturnManifest
+		page, err := paginate(query, q.PageQuery, manifestSortable, "-created_at", "manifest_id", &manifests)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"manifests": manifests, "total": page.Total, "page": page.Page, "page_size": page.PageSize})
+	}
+}
+
+// GetReturnManifest 交接单详情及其中的包裹，交接后的包裹仍可查到（管理员权限）
+func GetReturnManifest(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		reader := database.Reader(db).WithContext(ctx)
+		var manifest models.ReturnManifest
+		if err := reader.Omit("evidence").Where("manifest_id = ?", c.Param("manifest_id")).First(&manifest).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrManifestNotFound)
+			} else {
+				apierrors.Respond(c, apierrors.Internal(err))
+			}
+			return
+		}
+
+		var packs []models.Pack
+		if err := reader.Where("return_manifest_id = ?", manifest.ManifestId).Order("pack_id").Find(&packs).Error; err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"manifest": manifest, "packs": packs})
+	}
+}
+
+// HandoverReturnManifest 登记快递员取走交接单上的包裹，需上传签名或照片。包裹变为已退回，
+// 取件码与货架位置被释放，并通知收件人（管理员权限）
+func HandoverReturnManifest(db *gorm.DB, notifier *notify.Notifier, returnsCfg models.ReturnsConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// 预留 1MB 给表单中的其他字段
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, returnsCfg.MaxEvidenceBytes+1<<20)
+
+		var input models.HandoverInput
+		if err := c.ShouldBind(&input); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierrors.Respond(c, apierrors.ErrEvidenceInvalid)
+				return
+			}
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		evidence, contentType, err := readEvidence(c, returnsCfg.MaxEvidenceBytes)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		handedOverBy, _ := strconv.ParseInt(c.GetString("user_id"), 10, 64)
+		var manifest models.ReturnManifest
+		var packs []models.Pack
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			err := tx.Omit("evidence").Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("manifest_id = ?", c.Param("manifest_id")).First(&manifest).Error
+			if err == gorm.ErrRecordNotFound {
+				return apierrors.ErrManifestNotFound
+			}
+			if err != nil {
+				return err
+			}
+			if manifest.Status != models.ManifestOpen {
+				return apierrors.ErrManifestClosed
+			}
+
+			err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("return_manifest_id = ? AND pack_status = ?", manifest.ManifestId, models.PackStatusReturnPending).
+				Order("pack_id").Find(&packs).Error
+			if err != nil {
+				return err
+			}
+			if len(packs) == 0 {
+				return apierrors.ErrManifestEmpty
+			}
+
+			now := time.Now()
+			for i := range packs {
+				before := packs[i]
+				updates := map[string]any{"pack_status": models.PackStatusReturned, "pickup_code": "", "shelf_code": 0, "check_out_time": now}
+				if err := tx.Model(&packs[i]).Updates(updates).Error; err != nil {
+					return err
+				}
+				if err := audit.Record(c, tx, audit.Entry{Action: "pack.returned", TargetType: "pack", TargetId: packs[i].PackId, Before: before, After: packs[i]}); err != nil {
+					return err
+				}
+			}
+
+			before := manifest
+			err = tx.Model(&manifest).Updates(map[string]any{
+				"status":                models.ManifestHandedOver,
+				"handed_over_at":        now,
+				"handed_over_by":        handedOverBy,
+				"courier_name":          input.CourierName,
+				"courier_phone":         input.CourierPhone,
+				"evidence_type":         input.EvidenceType,
+				"evidence_content_type": contentType,
+				"evidence":              evidence,
+			}).Error
+			if err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "return_manifest.handover", TargetType: "return_manifest", TargetId: manifest.ManifestId, Before: before, After: manifest})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		notifyReturn(c, ctx, db, notifier, packs, notify.NoticePackReturned, map[string]any{"Carrier": manifest.Carrier})
+		c.JSON(http.StatusOK, gin.H{"manifest": manifest, "packs": packs})
+	}
+}
+
+// GetManifestEvidence 下载交接时上传的签名或照片（管理员权限）
+func GetManifestEvidence(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var manifest models.ReturnManifest
+		err := database.Reader(db).WithContext(ctx).
+			Where("manifest_id = ? AND evidence IS NOT NULL", c.Param("manifest_id")).First(&manifest).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrManifestNotFound)
+			} else {
+				apierrors.Respond(c, apierrors.Internal(err))
+			}
+			return
+		}
+
+		name := fmt.Sprintf("manifest-%d-%s%s", manifest.ManifestId, manifest.EvidenceType, evidenceTypes[manifest.EvidenceContentType])
+		c.Header("Content-Disposition", `inline; filename="`+name+`"`)
+		c.Data(http.StatusOK, manifest.EvidenceContentType, manifest.Evidence)
+	}
+}
+
+// lockPack 加锁读取包裹，不存在时返回 ErrPackNotFound
+func lockPack(tx *gorm.DB, packId string, pack *models.Pack) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("pack_id = ?", packId).First(pack).Error
+	if err == gorm.ErrRecordNotFound {
+		return apierrors.ErrPackNotFound
+	}
+	return err
+}
+
+// readEvidence 读取上传的凭证文件，按文件内容而不是文件名或客户端声明的类型判断格式
+func readEvidence(c *gin.Context, limit int64) ([]byte, string, error) {
+	header, err := c.FormFile("evidence")
+	if err != nil {
+		return nil, "", apierrors.ErrEvidenceInvalid
+	}
+	if header.Size == 0 || header.Size > limit {
+		return nil, "", apierrors.ErrEvidenceInvalid
+	}
+	f, err := header.Open()
+	if err != nil {
+		return nil, "", apierrors.Internal(err)
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, limit+1))
+	if err != nil {
+		return nil, "", apierrors.Internal(err)
+	}
+	if int64(len(data)) > limit {
+		return nil, "", apierrors.ErrEvidenceInvalid
+	}
+	contentType := http.DetectContentType(data)
+	if _, ok := evidenceTypes[contentType]; !ok {
+		return nil, "", apierrors.ErrEvidenceInvalid
+	}
+	return data, contentType, nil
+}
+
+// notifyReturn 提交后通知包裹的收件人，已删除的账号不通知，发送失败只记录日志
+func notifyReturn(c *gin.Context, ctx context.Context, db *gorm.DB, notifier *notify.Notifier, packs []models.Pack, code string, params map[string]any) {
+	ids := make([]int64, len(packs))
+	for i, p := range packs {
+		ids[i] = p.UserId
+	}
+	var users []models.User
+	if err := db.WithContext(ctx).Where("user_id IN ?", ids).Find(&users).Error; err != nil {
+		utils.Logger(c).Warn("failed to load return recipients", "error", err)
+		return
+	}
+	byId := make(map[int64]models.User, len(users))
+	for _, u := range users {
+		byId[u.UserId] = u
+	}
+
+	for _, p := range packs {
+		user, ok := byId[p.UserId]
+		if !ok {
+			continue
+		}
+		msg := notify.Message{Code: code, Params: map[string]any{"PackId": p.PackId}}
+		for k, v := range params {
+			msg.Params[k] = v
+		}
+		if err := notifier.Notify(ctx, user, msg); err != nil {
+			utils.Logger(c).Warn("failed to notify recipient", "pack_id", p.PackId, "error", err)
+		}
+	}
+}
+
+// uniqueIds 去掉重复的 ID
+func uniqueIds(ids []int64) map[int64]bool {
+	set := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
@@ -0,0 +1,68 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+)
+
+const defaultSearchLimit = 10
+
+// 完全匹配学号/手机号/单号的排最前，其次按三元组相似度排序；ILIKE '%q%' 由 pg_trgm GIN 索引加速
+const searchUsersSQL = `
+SELECT *, GREATEST(similarity(user_name, @q), similarity(student_id, @q), similarity(phone, @q)) AS score
+FROM users
+WHERE deleted_at IS NULL AND (user_name ILIKE @like OR student_id ILIKE @like OR phone ILIKE @like)
+ORDER BY (student_id = @q OR phone = @q) DESC, score DESC, user_id
+LIMIT @limit`
+
+const searchPacksSQL = `
+SELECT *, GREATEST(similarity(pack_id::text, @q), similarity(pickup_code, @q), similarity(tracking_number, @q)) AS score
+FROM packs
+WHERE deleted_at IS NULL AND (pack_id::text LIKE @like OR pickup_code ILIKE @like OR tracking_number ILIKE @like)
+ORDER BY (pack_id::text = @q OR pickup_code = @q OR tracking_number = @q) DESC, score DESC, check_in_time DESC
+LIMIT @limit`
+
+// Search 柜台综合搜索：同时按姓名/学号/手机号查用户、按包裹号/取件码/快递单号查包裹，支持部分匹配（如手机尾号）
+func Search(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q models.SearchQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		if q.Limit == 0 {
+			q.Limit = defaultSearchLimit
+		}
+
+		ctx, cancel := context.WithTimeout(c, 10*time.Second)
+		defer cancel()
+
+		term := strings.TrimSpace(q.Q)
+		args := map[string]interface{}{
+			"q":     term,
+			"like":  "%" + escapeLike(term) + "%",
+			"limit": q.Limit,
+		}
+
+		res := models.SearchResult{Users: []models.UserHit{}, Packs: []models.PackHit{}}
+		reader := database.Reader(db).WithContext(ctx)
+		if err := reader.Raw(searchUsersSQL, args).Scan(&res.Users).Error; err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		if err := reader.Raw(searchPacksSQL, args).Scan(&res.Packs).Error; err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, res)
+	}
+}
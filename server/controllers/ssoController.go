@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/sso"
+	"github.com/yurin-kami/PackChann/utils"
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const (
+	ssoCookie     = "sso_state"
+	ssoCookiePath = "/api/v1/auth/sso"
+	ssoCookieAge  = 10 * 60
+)
+
+// SSOProviders 列出已配置的外部身份提供方，供登录页展示按钮
+func SSOProviders(registry *sso.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"providers": registry.List()})
+	}
+}
+
+// SSOLogin 生成 state 与 PKCE verifier 存入 HttpOnly Cookie，然后跳转到 IdP 登录页
+func SSOLogin(registry *sso.Registry) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("provider")
+		p, _, ok := registry.Get(name)
+		if !ok {
+			apierrors.Respond(c, apierrors.ErrSSOProviderNotFound)
+			return
+		}
+
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		state := base64.RawURLEncoding.EncodeToString(raw)
+		verifier := oauth2.GenerateVerifier()
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		authURL, err := p.AuthURL(ctx, state, verifier)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.SetSameSite(http.SameSiteLaxMode)
+		c.SetCookie(ssoCookie, name+"|"+state+"|"+verifier, ssoCookieAge, ssoCookiePath, "", c.Request.TLS != nil, true)
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// ssoRedirect 浏览器跳回前端，结果放在 fragment 中，不会出现在服务器访问日志里
+func ssoRedirect(c *gin.Context, ssoCfg models.SSOConfig, values url.Values) {
+	c.Redirect(http.StatusFound, ssoCfg.FrontendCallback+"#"+values.Encode())
+}
+
+// SSOCallback IdP 回调：校验 state，向 IdP 换取身份，关联或创建本地用户，再带着一次性票据跳回前端
+func SSOCallback(db *gorm.DB, registry *sso.Registry, ssoCfg models.SSOConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("provider")
+		p, providerCfg, ok := registry.Get(name)
+		if !ok {
+			apierrors.Respond(c, apierrors.ErrSSOProviderNotFound)
+			return
+		}
+
+		fail := func(code apierrors.Code, err error) {
+			utils.Logger(c).Warn("sso login failed", "provider", name, "code", code, "error", err)
+			ssoRedirect(c, ssoCfg, url.Values{"error": {string(code)}})
+		}
+
+		cookie, _ := c.Cookie(ssoCookie)
+		c.SetCookie(ssoCookie, "", -1, ssoCookiePath, "", c.Request.TLS != nil, true)
+		parts := strings.SplitN(cookie, "|", 3)
+		if len(parts) != 3 || parts[0] != name ||
+			subtle.ConstantTimeCompare([]byte(parts[1]), []byte(c.Query("state"))) != 1 {
+			fail(apierrors.CodeSSOFailed, errors.New("state mismatch"))
+			return
+		}
+		state, verifier := parts[1], parts[2]
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		identity, err := p.Exchange(ctx, c.Request.URL.Query(), state, verifier)
+		if err != nil {
+			fail(apierrors.CodeSSOFailed, err)
+			return
+		}
+
+		user, err := resolveSSOUser(ctx, db, identity, providerCfg.AutoProvision)
+		if err != nil {
+			var apiErr *apierrors.Error
+			if errors.As(err, &apiErr) {
+				fail(apiErr.Code, err)
+			} else {
+				fail(apierrors.CodeInternal, err)
+			}
+			return
+		}
+
+		ticket, err := issueSSOTicket(ctx, db, user, ssoCfg.TicketTTL)
+		if err != nil {
+			fail(apierrors.CodeInternal, err)
+			return
+		}
+		ssoRedirect(c, ssoCfg, url.Values{"ticket": {ticket}})
+	}
+}
+
+// issueSSOTicket 生成随机票据并保存哈希，顺带清理已过期的票据
+func issueSSOTicket(ctx context.Context, db *gorm.DB, user models.User, ttl time.Duration) (string, error) {
+	ticket, hash, err := utils.NewSSOTicket()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("expires_at < ?", now).Delete(&models.SSOTicket{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.SSOTicket{UserId: user.UserId, TicketHash: hash, ExpiresAt: now.Add(ttl)}).Error
+	})
+	return ticket, err
+}
+
+// resolveSSOUser 按 (provider, subject) 找到已绑定的用户；未绑定时按学号关联已有用户，或在允许时自动创建
+func resolveSSOUser(ctx context.Context, db *gorm.DB, id *sso.Identity, autoProvision bool) (models.User, error) {
+	var user models.User
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("provider = ? AND subject = ?", id.Provider, id.Subject).First(&link).Error
+		if err == nil {
+			// 绑定的用户已被删除
+			if err := tx.Where("user_id = ?", link.UserId).First(&user).Error; errors.Is(err, gorm.ErrRecordNotFound) {
+				return apierrors.ErrSSONotLinked
+			} else if err != nil {
+				return err
+			}
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		if id.StudentId == "" {
+			return apierrors.ErrSSOProfileIncomplete
+		}
+		// 已删除的账号同样占用学号，不能自动关联也不能重新创建，需要管理员恢复
+		err = tx.Unscoped().Where("student_id = ?", id.StudentId).First(&user).Error
+		if err == nil && user.DeletedAt.Valid {
+			return apierrors.ErrSSONotLinked
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			if !autoProvision {
+				return apierrors.ErrSSONotLinked
+			}
+			if user, err = provisionSSOUser(tx, id); err != nil {
+				return err
+			}
+		} else if err != nil {
+			return err
+		}
+
+		return tx.Create(&models.UserIdentity{UserId: user.UserId, Provider: id.Provider, Subject: id.Subject}).Error
+	})
+	return user, err
+}
+
+// provisionSSOUser 为外部身份创建本地用户。不设置密码，只能通过 SSO 登录，之后可用找回密码设置本地密码
+func provisionSSOUser(tx *gorm.DB, id *sso.Identity) (models.User, error) {
+	if id.Phone == "" {
+		return models.User{}, apierrors.ErrSSOProfileIncomplete
+	}
+	var count int64
+	if err := tx.Unscoped().Model(&models.User{}).Where("phone = ?", id.Phone).Count(&count).Error; err != nil {
+		return models.User{}, err
+	}
+	if count > 0 {
+		return models.User{}, apierrors.ErrUserAlreadyExists
+	}
+
+	userId, err := utils.GenerateID()
+	if err != nil {
+		return models.User{}, err
+	}
+	name := id.Name
+	if name == "" {
+		name = id.StudentId
+	}
+	user := models.User{
+		UserId:    userId,
+		UserName:  name,
+		StudentId: id.StudentId,
+		Phone:     id.Phone,
+		Role:      "user",
+	}
+	return user, tx.Create(&user).Error
+}
+
+// SSOExchange 前端用一次性票据换取登录结果，与密码登录一样会进入两步验证
+func SSOExchange(db *gorm.DB, keys *keyring.Keyring, mfaCfg models.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.SSOExchangeInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		userId, err := consumeSSOTicket(ctx, db, input.Ticket)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		user, err := findUser(ctx, db, userId)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		completeLogin(c, ctx, db, user, keys, mfaCfg)
+	}
+}
+
+// consumeSSOTicket 兑换票据并返回用户 ID。条件更新保证同一票据并发兑换时只有一次成功
+func consumeSSOTicket(ctx context.Context, db *gorm.DB, ticket string) (string, error) {
+	var row models.SSOTicket
+	err := db.WithContext(ctx).
+		Where("ticket_hash = ? AND used_at IS NULL AND expires_at > ?", utils.HashAPIKey(ticket), time.Now()).
+		First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", apierrors.ErrSSOFailed
+	} else if err != nil {
+		return "", apierrors.Internal(err)
+	}
+
+	used := db.WithContext(ctx).Model(&row).Where("used_at IS NULL").Update("used_at", time.Now())
+	if used.Error != nil {
+		return "", apierrors.Internal(used.Error)
+	}
+	if used.RowsAffected == 0 {
+		return "", apierrors.ErrSSOFailed
+	}
+	return fmt.Sprint(row.UserId), nil
+}
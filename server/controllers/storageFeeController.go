@@ -0,0 +1,165 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+)
+
+// GetPackFees 包裹的保管费流水与待收金额，仍待取的包裹同时返回按当前时间计算的费用（管理员权限）
+func GetPackFees(db *gorm.DB, feeCfg models.StorageFeeConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		reader := database.Reader(db).WithContext(ctx)
+		var pack models.Pack
+		if err := reader.Where("pack_id = ?", c.Param("pack_id")).First(&pack).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrPackNotFound)
+			} else {
+				apierrors.Respond(c, apierrors.Internal(err))
+			}
+			return
+		}
+
+		entries, err := feeEntries(reader, pack.PackId)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		resp := gin.H{"entries": entries, "balance": feeBalance(entries)}
+		if feeCfg.Enabled && pack.PackStatus == models.PackStatusPending {
+			quote, err := quoteFee(reader, feeCfg, pack, time.Now())
+			if err != nil {
+				apierrors.Respond(c, apierrors.Internal(err))
+				return
+			}
+			resp["quote"] = quote
+			resp["waived"] = feeWaived(entries)
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+// WaiveStorageFee 减免包裹的保管费，必须填写原因。已取件的包裹冲销全部待收金额，待取的包裹在取件时不再收费（管理员权限）
+func WaiveStorageFee(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.WaiveFeeInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		createdBy, _ := strconv.ParseInt(c.GetString("user_id"), 10, 64)
+		var entry models.StorageFee
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var pack models.Pack
+			if err := lockPack(tx, strconv.FormatInt(input.PackId, 10), &pack); err != nil {
+				return err
+			}
+			entries, err := feeEntries(tx, pack.PackId)
+			if err != nil {
+				return err
+			}
+
+			balance := feeBalance(entries)
+			if pack.PackStatus == models.PackStatusPending {
+				if feeWaived(entries) {
+					return apierrors.ErrStorageFeeNotDue
+				}
+			} else if balance <= 0 {
+				return apierrors.ErrStorageFeeNotDue
+			}
+
+			entry = models.StorageFee{
+				PackId:    pack.PackId,
+				UserId:    pack.UserId,
+				Kind:      models.FeeWaiver,
+				Amount:    -balance,
+				Note:      input.Reason,
+				CreatedBy: createdBy,
+			}
+			if err := tx.Create(&entry).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "storage_fee.waive", TargetType: "pack", TargetId: pack.PackId, After: entry})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"entry": entry})
+	}
+}
+
+// chargeStorageFee 取件时按当前策略记一笔收费，需在取件的事务中调用。取件前已减免的包裹记 0
+func chargeStorageFee(tx *gorm.DB, feeCfg models.StorageFeeConfig, pack models.Pack, now time.Time) (*models.StorageFee, error) {
+	quote, err := quoteFee(tx, feeCfg, pack, now)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := feeEntries(tx, pack.PackId)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := models.StorageFee{PackId: pack.PackId, UserId: pack.UserId, Kind: models.FeeCharge, Amount: quote.Amount, Days: quote.ChargeableDays}
+	switch {
+	case feeWaived(entries):
+		entry.Amount, entry.Note = 0, models.FeeNoteWaived
+	case quote.Exempt:
+		entry.Note = models.FeeNoteExemptRole
+	case quote.ChargeableDays == 0:
+		entry.Note = models.FeeNoteFreePeriod
+	}
+	if err := tx.Create(&entry).Error; err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// quoteFee 按收件人角色与包裹尺寸计算截至 now 的保管费，收件人已删除时按普通用户计算
+func quoteFee(tx *gorm.DB, feeCfg models.StorageFeeConfig, pack models.Pack, now time.Time) (models.FeeQuote, error) {
+	var roles []string
+	if err := tx.Unscoped().Model(&models.User{}).Where("user_id = ?", pack.UserId).Pluck("role", &roles).Error; err != nil {
+		return models.FeeQuote{}, err
+	}
+	role := ""
+	if len(roles) > 0 {
+		role = roles[0]
+	}
+	return feeCfg.Quote(role, pack.Size, now.Sub(pack.CheckInTime)), nil
+}
+
+func feeEntries(tx *gorm.DB, packId int64) ([]models.StorageFee, error) {
+	entries := []models.StorageFee{}
+	err := tx.Where("pack_id = ?", packId).Order("entry_id").Find(&entries).Error
+	return entries, err
+}
+
+func feeBalance(entries []models.StorageFee) int64 {
+	var sum int64
+	for _, e := range entries {
+		sum += e.Amount
+	}
+	return sum
+}
+
+func feeWaived(entries []models.StorageFee) bool {
+	return slices.ContainsFunc(entries, func(e models.StorageFee) bool { return e.Kind == models.FeeWaiver })
+}
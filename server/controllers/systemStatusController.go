@@ -10,6 +10,8 @@ import (
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
 	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/database"
 	"github.com/yurin-kami/PackChann/models"
 )
 
@@ -60,15 +62,16 @@ func GetSystemStatus() gin.HandlerFunc {
 
 		cpu, mem, swap, disks, err := getUsage()
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
 		res := &models.SystemStatus{
-			Cpu:    cpu,
-			Memory: mem,
-			Swap:   swap,
-			Disk:   disks,
+			Cpu:      cpu,
+			Memory:   mem,
+			Swap:     swap,
+			Disk:     disks,
+			Database: database.PoolStats(),
 		}
 
 		c.JSON(http.StatusOK, gin.H{"Usage": res})
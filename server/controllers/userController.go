@@ -2,19 +2,31 @@ package controllers
 
 import (
 	"context"
+	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/i18n"
+	"github.com/yurin-kami/PackChann/keyring"
 	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/ratelimit"
 	"github.com/yurin-kami/PackChann/utils"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func passwordHash(password string) string {
-	bytes, _ := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	return string(bytes)
+func passwordHash(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
 }
 
 // 验证密码
@@ -41,14 +53,15 @@ type RegisterInput struct {
 	StudentId string `json:"student_id" binding:"required"`
 	Phone     string `json:"phone" binding:"required"`
 	Address   string `json:"address"`
-	Role      string `json:"role"` // Optional, default to 'user'
+	Role      string `json:"role"`     // Optional, default to 'user'
+	Language  string `json:"language"` // Optional, zh-CN / en
 }
 
-func RegisterUser(db *gorm.DB, jwtCfg models.JWTConfig) gin.HandlerFunc {
+func RegisterUser(db *gorm.DB, keys *keyring.Keyring, policy models.PasswordConfig) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var input RegisterInput
-		if err := c.ShouldBindJSON(&input); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input: " + err.Error()})
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 
@@ -58,25 +71,36 @@ func RegisterUser(db *gorm.DB, jwtCfg models.JWTConfig) gin.HandlerFunc {
 		}
 
 		newUser := models.User{
-			UserName:     input.UserName,
-			PasswordHash: passwordHash(input.Password),
-			StudentId:    input.StudentId,
-			Phone:        input.Phone,
-			Address:      input.Address,
-			Role:         role,
+			UserName:  input.UserName,
+			StudentId: input.StudentId,
+			Phone:     input.Phone,
+			Address:   input.Address,
+			Role:      role,
+			Language:  i18n.Normalize(input.Language),
+		}
+		if err := checkPasswordPolicy(policy, input.Password, newUser); err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		hash, err := passwordHash(input.Password)
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
 		}
+		newUser.PasswordHash = hash
 
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
+		// 已删除的账号仍占用学号与手机号，由管理员恢复
 		var existingUser models.User
-		err := db.WithContext(ctx).Where("student_id = ? OR phone = ?", newUser.StudentId, newUser.Phone).First(&existingUser).Error
+		err = db.WithContext(ctx).Unscoped().Where("student_id = ? OR phone = ?", newUser.StudentId, newUser.Phone).First(&existingUser).Error
 		if err == nil {
-			c.JSON(http.StatusConflict, gin.H{"error": "User already exists"})
+			apierrors.Respond(c, apierrors.ErrUserAlreadyExists)
 			return
 		}
 		if err != gorm.ErrRecordNotFound {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -84,25 +108,25 @@ func RegisterUser(db *gorm.DB, jwtCfg models.JWTConfig) gin.HandlerFunc {
 		if id, err := utils.GenerateID(); err == nil {
 			newUser.UserId = id
 		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate user id"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
 		if err := db.WithContext(ctx).Create(&newUser).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
 		// 生成 Token
-		accessToken, refreshToken, err := utils.GenerateAllToken(newUser, jwtCfg)
+		accessToken, refreshToken, err := utils.GenerateAllToken(newUser, keys)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
 		// 存储 Token
-		if err := saveTokenToDB(ctx, db, newUser.UserId, accessToken, refreshToken, jwtCfg.ExpirationHours); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save token"})
+		if err := saveTokenToDB(ctx, db, newUser.UserId, accessToken, refreshToken, keys.Config().ExpirationHours); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
@@ -114,127 +138,492 @@ func RegisterUser(db *gorm.DB, jwtCfg models.JWTConfig) gin.HandlerFunc {
 	}
 }
 
-func LoginUser(db *gorm.DB, jwtCfg models.JWTConfig) gin.HandlerFunc {
+// loginFailed 记录一次登录失败，达到上限时锁定账号并写审计日志。学号不存在也计数，避免借锁定行为探测账号是否存在
+func loginFailed(c *gin.Context, db *gorm.DB, limiter *ratelimit.Limiter, studentId string) {
+	recordLoginFailure(c, db, limiter, studentId)
+	apierrors.Respond(c, apierrors.ErrInvalidCredentials)
+}
+
+func recordLoginFailure(c *gin.Context, db *gorm.DB, limiter *ratelimit.Limiter, studentId string) {
+	locked, err := limiter.RecordFailure(c, studentId)
+	if err != nil {
+		utils.Logger(c).Warn("rate limit store unavailable", "error", err)
+	}
+	if locked {
+		// 锁定状态在限流存储中，审计记录单独写入。审计记录不能删除，只记录用户 ID 不记录学号；账号不存在时为 0
+		var userId int64
+		if err := db.WithContext(c).Model(&models.User{}).Where("student_id = ?", studentId).Pluck("user_id", &userId).Error; err != nil {
+			utils.Logger(c).Warn("failed to look up locked account", "error", err)
+		}
+		err := audit.Record(c, db.WithContext(c), audit.Entry{Action: "account.lock", TargetType: "user", TargetId: userId})
+		if err != nil {
+			utils.Logger(c).Warn("failed to write audit log", "action", "account.lock", "error", err)
+		}
+	}
+}
+
+func LoginUser(db *gorm.DB, keys *keyring.Keyring, mfaCfg models.MFAConfig, limiter *ratelimit.Limiter) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var loginInput models.UserLogin
-		if err := c.ShouldBindJSON(&loginInput); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input"})
+		if err := bindJSON(c, &loginInput); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
 			return
 		}
 
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
+
+		// 锁定期间即使密码正确也拒绝登录
+		wait, err := limiter.LockedFor(ctx, loginInput.StudentId)
+		if err != nil {
+			utils.Logger(c).Warn("rate limit store unavailable", "error", err)
+		}
+		if wait > 0 {
+			c.Header("Retry-After", ratelimit.RetryAfter(wait))
+			apierrors.Respond(c, apierrors.ErrAccountLocked)
+			return
+		}
+
 		var user models.User
-		err := db.WithContext(ctx).Where("student_id = ?", loginInput.StudentId).First(&user).Error
+		err = db.WithContext(ctx).Where("student_id = ?", loginInput.StudentId).First(&user).Error
 		if err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+				loginFailed(c, db, limiter, loginInput.StudentId)
 			} else {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+				apierrors.Respond(c, apierrors.Internal(err))
 			}
 			return
 		}
 
 		// 使用 bcrypt 验证密码
 		if !checkPassword(user.PasswordHash, loginInput.Password) {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid credentials"})
+			loginFailed(c, db, limiter, loginInput.StudentId)
 			return
 		}
+		if err := limiter.Reset(ctx, loginInput.StudentId); err != nil {
+			utils.Logger(c).Warn("rate limit store unavailable", "error", err)
+		}
 
-		// 生成 Token
-		accessToken, refreshToken, err := utils.GenerateAllToken(user, jwtCfg)
+		completeLogin(c, ctx, db, user, keys, mfaCfg)
+	}
+}
+
+// completeLogin 身份已确认（密码或外部身份提供方）后的统一出口。
+// 已开启两步验证，或角色强制要求两步验证时，先签发临时 mfa_token，完成第二步后才发放正式 Token
+func completeLogin(c *gin.Context, ctx context.Context, db *gorm.DB, user models.User, keys *keyring.Keyring, mfaCfg models.MFAConfig) {
+	if user.TOTPEnabled || mfaCfg.Requires(user.Role) {
+		mfaToken, err := utils.GeneratePurposeToken(user, keys, utils.PurposeMFA, mfaCfg.TokenTTL)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate tokens"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{
+			"mfa_required":       user.TOTPEnabled,
+			"mfa_setup_required": !user.TOTPEnabled,
+			"mfa_token":          mfaToken,
+		})
+		return
+	}
 
-		// 存储 Token
-		if err := saveTokenToDB(ctx, db, user.UserId, accessToken, refreshToken, jwtCfg.ExpirationHours); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save token"})
+	respondWithTokens(c, ctx, db, user, keys, nil)
+}
+
+// respondWithTokens 生成并保存 Token，返回登录响应；extra 中的字段会合并进响应体
+func respondWithTokens(c *gin.Context, ctx context.Context, db *gorm.DB, user models.User, keys *keyring.Keyring, extra gin.H) {
+	// 生成 Token
+	accessToken, refreshToken, err := utils.GenerateAllToken(user, keys)
+	if err != nil {
+		apierrors.Respond(c, apierrors.Internal(err))
+		return
+	}
+
+	// 存储 Token
+	if err := saveTokenToDB(ctx, db, user.UserId, accessToken, refreshToken, keys.Config().ExpirationHours); err != nil {
+		apierrors.Respond(c, apierrors.Internal(err))
+		return
+	}
+
+	resp := gin.H{
+		"user": models.User{
+			UserId:      user.UserId,
+			UserName:    user.UserName,
+			Phone:       user.Phone,
+			Address:     user.Address,
+			StudentId:   user.StudentId,
+			Role:        user.Role,
+			Language:    user.Language,
+			TOTPEnabled: user.TOTPEnabled,
+		},
+		"access_token":  accessToken,
+		"refresh_token": refreshToken,
+	}
+	for k, v := range extra {
+		resp[k] = v
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateUserInfoByPhone 修改用户资料（姓名、地址、手机号、语言偏好）。普通用户只能修改自己；
+// 管理员可以修改任何用户，与 /admin 接口一样需要满足两步验证要求
+func UpdateUserInfoByPhone(db *gorm.DB, mfaCfg models.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var input models.UpdateUserInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		if role := c.GetString("role"); c.GetString("user_id") != fmt.Sprint(input.UserId) {
+			if role != "admin" {
+				apierrors.Respond(c, apierrors.ErrForbidden)
+				return
+			}
+			if mfaCfg.Requires(role) && !c.GetBool("totp_enabled") {
+				apierrors.Respond(c, apierrors.ErrMFARequired)
+				return
+			}
+		}
+
+		updates := map[string]any{}
+		if input.UserName != nil {
+			updates["user_name"] = *input.UserName
+		}
+		if input.Address != nil {
+			updates["address"] = *input.Address
+		}
+		if input.Phone != nil {
+			updates["phone"] = *input.Phone
+		}
+		if input.Language != nil {
+			lang := i18n.Normalize(*input.Language)
+			if lang == "" && *input.Language != "" {
+				apierrors.Respond(c, apierrors.ErrInvalidInput.WithDetails(apierrors.FieldError{Field: "language", Rule: "oneof", Param: "zh-CN en"}))
+				return
+			}
+			updates["language"] = lang
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		user, err := findUser(ctx, db, fmt.Sprint(input.UserId))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if len(updates) == 0 {
+			c.JSON(http.StatusOK, gin.H{"update_user": user})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{
-			"user": models.User{
-				UserId:    user.UserId,
-				UserName:  user.UserName,
-				Phone:     user.Phone,
-				Address:   user.Address,
-				StudentId: user.StudentId,
-				Role:      user.Role,
-			},
-			"access_token":  accessToken,
-			"refresh_token": refreshToken,
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if phone, ok := updates["phone"]; ok && phone != user.Phone {
+				// 已删除的账号同样占用手机号
+				var count int64
+				if err := tx.Unscoped().Model(&models.User{}).Where("phone = ? AND user_id <> ?", phone, user.UserId).Count(&count).Error; err != nil {
+					return err
+				}
+				if count > 0 {
+					return apierrors.ErrUserAlreadyExists
+				}
+			}
+
+			before := user
+			columns := make([]string, 0, len(updates))
+			for column := range updates {
+				columns = append(columns, column)
+			}
+			if err := tx.Model(&user).Select(columns).Updates(updates).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "user.update", TargetType: "user", TargetId: user.UserId, Before: before, After: user})
 		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"update_user": user})
 	}
 }
 
-func UpdateUserInfoByPhone(db *gorm.DB) gin.HandlerFunc {
+// UpdateUserRole 修改用户角色（管理员权限）。Token 中带有角色，修改后吊销该用户的全部 Token，需要重新登录；
+// 不能修改自己的角色，避免误操作后没有管理员
+func UpdateUserRole(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var updateUser models.User
-		if err := c.ShouldBindJSON(&updateUser); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "input Invalid"})
+		var input models.UpdateRoleInput
+		if err := bindJSON(c, &input); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+		if c.GetString("user_id") == fmt.Sprint(input.UserId) {
+			apierrors.Respond(c, apierrors.ErrForbidden)
 			return
 		}
 
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		var existingUser models.User
-		if err := db.WithContext(ctx).Where("user_id = ?", updateUser.UserId).First(&existingUser).Error; err != nil {
-			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
-				return
-			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		user, err := findUser(ctx, db, fmt.Sprint(input.UserId))
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+		if user.Role == input.Role {
+			c.JSON(http.StatusOK, gin.H{"user": user})
 			return
 		}
 
-		if err := db.WithContext(ctx).Model(&existingUser).Updates(&updateUser).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		err = db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			before := user
+			if err := tx.Model(&user).Update("role", input.Role).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.UserId).Delete(&models.UserToken{}).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "user.role", TargetType: "user", TargetId: user.UserId, Before: before, After: user})
+		})
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"update_user": existingUser})
+		c.JSON(http.StatusOK, gin.H{"user": user})
 	}
 }
 
-// GetAllUsers 获取所有用户（管理员权限）
+// userSortable 用户列表允许排序的字段
+var userSortable = map[string]string{
+	"register_time": "register_time",
+	"student_id":    "student_id",
+	"user_name":     "user_name",
+	"role":          "role",
+}
+
+// GetAllUsers 获取所有用户（管理员权限），支持分页、排序与按角色筛选
 func GetAllUsers(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var q models.UserListQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		query := database.Reader(db).WithContext(ctx).Model(&models.User{})
+		if q.Deleted {
+			query = query.Unscoped().Where("deleted_at IS NOT NULL")
+		}
+		if q.Role != "" {
+			query = query.Where("role = ?", q.Role)
+		}
+		if term := strings.TrimSpace(q.Q); term != "" {
+			// 与全局搜索相同的 ILIKE 条件，由 pg_trgm GIN 索引加速
+			like := "%" + escapeLike(term) + "%"
+			query = query.Where("(user_name ILIKE ? OR student_id ILIKE ? OR phone ILIKE ?)", like, like, like)
+		}
+
 		var users []models.User
+		page, err := paginate(query, q.PageQuery, userSortable, "-register_time", "user_id", &users)
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"users": users, "total": page.Total, "page": page.Page, "page_size": page.PageSize})
+	}
+}
+
+// uncollectedPacks 用户在站点中尚未取走的包裹，lock 为 true 时加行锁，防止删除过程中被取件或改动
+func uncollectedPacks(tx *gorm.DB, userId int64, lock bool) ([]models.Pack, error) {
+	packs := []models.Pack{}
+	query := tx.Where("user_id = ? AND pack_status = ?", userId, models.PackStatusPending).Order("check_in_time")
+	if lock {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	return packs, query.Find(&packs).Error
+}
+
+// UserDeletionCheck 删除用户前的检查：列出未取件包裹与仍然有效的会话（管理员权限）
+func UserDeletionCheck(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
-		if err := db.WithContext(ctx).Find(&users).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		var check models.UserDeletionCheck
+		if err := db.WithContext(ctx).Where("user_id = ?", c.Param("user_id")).First(&check.User).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrUserNotFound)
+				return
+			}
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"users": users})
+		var err error
+		if check.UncollectedPacks, err = uncollectedPacks(db.WithContext(ctx), check.User.UserId, false); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		err = db.WithContext(ctx).Model(&models.UserToken{}).
+			Where("user_id = ? AND expires_at > ?", check.User.UserId, time.Now()).
+			Count(&check.ActiveSessions).Error
+		if err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		c.JSON(http.StatusOK, check)
 	}
 }
 
+// DeleteUser 软删除用户并吊销其全部 Token。未取件的包裹按 parcels 参数处理，默认存在未取件包裹时拒绝删除
 func DeleteUser(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		var q models.DeleteUserQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			apierrors.Respond(c, apierrors.Binding(err))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
 		var user models.User
+		var handled []int64
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("user_id = ?", c.Param("user_id")).First(&user).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return apierrors.ErrUserNotFound
+				}
+				return err
+			}
+
+			packs, err := uncollectedPacks(tx, user.UserId, true)
+			if err != nil {
+				return err
+			}
+			if len(packs) > 0 {
+				if handled, err = handleUncollectedPacks(c, tx, user, packs, q); err != nil {
+					return err
+				}
+			}
+
+			if err := tx.Delete(&user).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("user_id = ?", user.UserId).Delete(&models.UserToken{}).Error; err != nil {
+				return err
+			}
+			return audit.Record(c, tx, audit.Entry{Action: "user.delete", TargetType: "user", TargetId: user.UserId, Before: user})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "delete user complete", "packs": handled})
+	}
+}
+
+// handleUncollectedPacks 按 q.Parcels 转交或退回被删除用户的未取件包裹，返回处理过的包裹 ID
+func handleUncollectedPacks(c *gin.Context, tx *gorm.DB, user models.User, packs []models.Pack, q models.DeleteUserQuery) ([]int64, error) {
+	var action string
+	updates := map[string]interface{}{}
+	switch q.Parcels {
+	case models.ParcelsTransfer:
+		if q.TransferTo == user.UserId {
+			return nil, apierrors.ErrInvalidInput.WithDetails(apierrors.FieldError{Field: "transfer_to", Rule: "ne", Param: "user_id"})
+		}
+		var count int64
+		if err := tx.Model(&models.User{}).Where("user_id = ?", q.TransferTo).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count == 0 {
+			return nil, apierrors.ErrUserNotFound
+		}
+		action, updates["user_id"] = "pack.transfer", q.TransferTo
+	case models.ParcelsReturn:
+		action, updates["pack_status"] = "pack.return", models.PackStatusReturnPending
+	default:
+		return nil, apierrors.ErrUserHasUncollected
+	}
+
+	ids := make([]int64, len(packs))
+	for i, pack := range packs {
+		before := pack
+		if err := tx.Model(&pack).Updates(updates).Error; err != nil {
+			return nil, err
+		}
+		if err := audit.Record(c, tx, audit.Entry{Action: action, TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack}); err != nil {
+			return nil, err
+		}
+		ids[i] = pack.PackId
+	}
+	return ids, nil
+}
+
+// RestoreUser 恢复已删除的用户，被转交或退回的包裹不会恢复（管理员权限）
+func RestoreUser(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
 		ctx, cancel := context.WithTimeout(c, 30*time.Second)
 		defer cancel()
 
+		err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			var user models.User
+			err := tx.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", c.Param("user_id")).First(&user).Error
+			if err != nil {
+				if err == gorm.ErrRecordNotFound {
+					return apierrors.ErrUserNotFound
+				}
+				return err
+			}
+			if user.AnonymizedAt != nil {
+				return apierrors.ErrUserAnonymized
+			}
+			before := user
+			if err := tx.Unscoped().Model(&user).Update("deleted_at", nil).Error; err != nil {
+				return err
+			}
+			user.DeletedAt = gorm.DeletedAt{}
+			return audit.Record(c, tx, audit.Entry{Action: "user.restore", TargetType: "user", TargetId: user.UserId, Before: before, After: user})
+		})
+		if err != nil {
+			apierrors.Respond(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "restore user complete"})
+	}
+}
+
+// UnlockUser 管理员解除账号的登录锁定并清空失败计数
+func UnlockUser(db *gorm.DB, limiter *ratelimit.Limiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c, 30*time.Second)
+		defer cancel()
+
+		var user models.User
 		if err := db.WithContext(ctx).Where("user_id = ?", c.Param("user_id")).First(&user).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+				apierrors.Respond(c, apierrors.ErrUserNotFound)
 				return
 			}
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
-		if err := db.WithContext(ctx).Delete(&user).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		if err := limiter.Unlock(ctx, user.StudentId); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+		if err := audit.Record(c, db.WithContext(ctx), audit.Entry{Action: "account.unlock", TargetType: "user", TargetId: user.UserId}); err != nil {
+			apierrors.Respond(c, apierrors.Internal(err))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "delete user complete"})
+		c.JSON(http.StatusOK, gin.H{"message": "unlock user complete"})
 	}
 }
@@ -2,30 +2,230 @@ package database
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
+	"log/slog"
+	"sort"
+	"time"
 
 	"github.com/yurin-kami/PackChann/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+	"gorm.io/plugin/dbresolver"
 )
 
-func NewConnection(ctx context.Context, cfg models.DBConfig) (*gorm.DB, error) {
+// replicaResolver 只读副本在 dbresolver 中注册的名称
+const replicaResolver = "replica"
+
+// pools 记录主库与各副本的连接池，用于统计与关闭
+var pools = map[string]*sql.DB{}
+
+func dsn(host string, port int, user, password string, cfg models.DBConfig) string {
+	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+		host, port, user, password, cfg.DBName, cfg.SSLMode)
+	if cfg.SSLRootCert != "" {
+		dsn += " sslrootcert=" + cfg.SSLRootCert
+	}
+	return dsn
+}
+
+func configurePool(sqlDB *sql.DB, cfg models.DBConfig) {
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+}
+
+func gormConfig(logger *slog.Logger) *gorm.Config {
+	return &gorm.Config{
+		Logger: gormlogger.NewSlogLogger(logger, gormlogger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  gormlogger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	}
+}
+
+func NewConnection(ctx context.Context, cfg models.DBConfig, logger *slog.Logger) (*gorm.DB, error) {
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
-	dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
-		cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg.DBName)
+	db, err := gorm.Open(postgres.Open(dsn(cfg.Host, cfg.Port, cfg.User, cfg.Password, cfg)), gormConfig(logger))
+	if err != nil {
+		return nil, err
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	sqlDB, err := db.DB()
 	if err != nil {
 		return nil, err
 	}
+	configurePool(sqlDB, cfg)
+	pools["primary"] = sqlDB
+
+	if err := registerReplicas(db, cfg, logger); err != nil {
+		return nil, err
+	}
 
 	// Auto Migrate the schema
-	err = db.AutoMigrate(&models.User{}, &models.Pack{}, &models.Notice{}, &models.UserToken{})
+	err = db.WithContext(ctx).AutoMigrate(Models()...)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := migrateSearchIndexes(ctx, db); err != nil {
+		return nil, err
+	}
+
+	if err := migrateAuditLog(ctx, db); err != nil {
+		return nil, err
+	}
+
 	return db, nil
 }
+
+// registerReplicas 将只读副本注册为命名 resolver，只有通过 Reader 显式指定的查询才会路由过去
+func registerReplicas(db *gorm.DB, cfg models.DBConfig, logger *slog.Logger) error {
+	if len(cfg.Replicas) == 0 {
+		return nil
+	}
+
+	var replicas []gorm.Dialector
+	for i, r := range cfg.Replicas {
+		user, password, port := r.User, r.Password, r.Port
+		if user == "" {
+			user = cfg.User
+		}
+		if password == "" {
+			password = cfg.Password
+		}
+		if port == 0 {
+			port = cfg.Port
+		}
+
+		replicaDB, err := gorm.Open(postgres.Open(dsn(r.Host, port, user, password, cfg)), gormConfig(logger))
+		if err != nil {
+			return fmt.Errorf("replica %s: %w", r.Host, err)
+		}
+		sqlDB, err := replicaDB.DB()
+		if err != nil {
+			return err
+		}
+		configurePool(sqlDB, cfg)
+		pools[fmt.Sprintf("replica-%d", i)] = sqlDB
+
+		replicas = append(replicas, postgres.New(postgres.Config{Conn: sqlDB}))
+	}
+
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: replicas,
+		Policy:   dbresolver.RandomPolicy{},
+	}, replicaResolver))
+}
+
+// Reader 返回走只读副本的查询句柄，未配置副本时仍使用主库
+func Reader(db *gorm.DB) *gorm.DB {
+	return db.Clauses(dbresolver.Use(replicaResolver))
+}
+
+// PoolStats 返回主库与各副本的连接池统计
+func PoolStats() []models.DBPoolStatus {
+	var stats []models.DBPoolStatus
+	for name, sqlDB := range pools {
+		s := sqlDB.Stats()
+		stats = append(stats, models.DBPoolStatus{
+			Name:              name,
+			MaxOpenConns:      s.MaxOpenConnections,
+			OpenConns:         s.OpenConnections,
+			InUse:             s.InUse,
+			Idle:              s.Idle,
+			WaitCount:         s.WaitCount,
+			WaitDuration:      s.WaitDuration.String(),
+			MaxIdleClosed:     s.MaxIdleClosed,
+			MaxIdleTimeClosed: s.MaxIdleTimeClosed,
+			MaxLifetimeClosed: s.MaxLifetimeClosed,
+		})
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	return stats
+}
+
+// Pools 返回主库与各副本的连接池，键为连接池名称
+func Pools() map[string]*sql.DB {
+	res := make(map[string]*sql.DB, len(pools))
+	for name, sqlDB := range pools {
+		res[name] = sqlDB
+	}
+	return res
+}
+
+// Close 关闭主库与所有副本的连接池
+func Close() error {
+	var firstErr error
+	for _, sqlDB := range pools {
+		if err := sqlDB.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// searchIndexes 综合搜索使用的 pg_trgm GIN 索引，AutoMigrate 无法表达，单独创建
+var searchIndexes = []string{
+	`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+	`CREATE INDEX IF NOT EXISTS idx_users_user_name_trgm ON users USING gin (user_name gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_users_student_id_trgm ON users USING gin (student_id gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_users_phone_trgm ON users USING gin (phone gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_packs_pack_id_trgm ON packs USING gin ((pack_id::text) gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_packs_pickup_code_trgm ON packs USING gin (pickup_code gin_trgm_ops)`,
+	`CREATE INDEX IF NOT EXISTS idx_packs_tracking_number_trgm ON packs USING gin (tracking_number gin_trgm_ops)`,
+}
+
+func migrateSearchIndexes(ctx context.Context, db *gorm.DB) error {
+	for _, stmt := range searchIndexes {
+		if err := db.WithContext(ctx).Exec(stmt).Error; err != nil {
+			return fmt.Errorf("search index: %w", err)
+		}
+	}
+	return nil
+}
+
+// auditLogTriggers 审计表只允许追加，拒绝修改、删除与清空
+var auditLogTriggers = []string{
+	`CREATE OR REPLACE FUNCTION audit_log_append_only() RETURNS trigger AS $$
+BEGIN
+	RAISE EXCEPTION 'audit_log is append-only';
+END
+$$ LANGUAGE plpgsql`,
+	`DROP TRIGGER IF EXISTS audit_log_no_modify ON audit_log`,
+	`CREATE TRIGGER audit_log_no_modify BEFORE UPDATE OR DELETE ON audit_log FOR EACH ROW EXECUTE FUNCTION audit_log_append_only()`,
+	`DROP TRIGGER IF EXISTS audit_log_no_truncate ON audit_log`,
+	`CREATE TRIGGER audit_log_no_truncate BEFORE TRUNCATE ON audit_log FOR EACH STATEMENT EXECUTE FUNCTION audit_log_append_only()`,
+}
+
+func migrateAuditLog(ctx context.Context, db *gorm.DB) error {
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range auditLogTriggers {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return fmt.Errorf("audit log trigger: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// Models 返回需要自动迁移的全部模型
+func Models() []interface{} {
+	return []interface{}{&models.User{}, &models.Pack{}, &models.Notice{}, &models.UserToken{}, &models.PasswordReset{}, &models.RecoveryCode{}, &models.UserIdentity{}, &models.SSOTicket{}, &models.SigningKey{}, &models.APIKey{}, &models.AuditLog{}, &models.ReturnManifest{}, &models.StorageFee{}}
+}
+
+// CheckMigrations 检查所有模型对应的表是否已经创建
+func CheckMigrations(ctx context.Context, db *gorm.DB) error {
+	migrator := db.WithContext(ctx).Migrator()
+	for _, model := range Models() {
+		if !migrator.HasTable(model) {
+			return fmt.Errorf("table for %T is missing", model)
+		}
+	}
+	return nil
+}
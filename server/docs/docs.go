@@ -0,0 +1,55 @@
+package docs
+
+import (
+	"embed"
+	"net/http"
+	"path"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Spec 手工维护的 OpenAPI 3 文档，新增或修改路由时需要同步更新（routes 包的测试会检查遗漏）
+//
+//go:embed openapi.json
+var Spec []byte
+
+//go:embed swagger.html
+var swaggerHTML []byte
+
+// swaggerAssets 本地打包的 swagger-ui-dist，版本见 swagger-ui/README.md
+//
+//go:embed swagger-ui/swagger-ui-bundle.js swagger-ui/swagger-ui.css
+var swaggerAssets embed.FS
+
+var assetTypes = map[string]string{
+	".js":  "text/javascript; charset=utf-8",
+	".css": "text/css; charset=utf-8",
+}
+
+// OpenAPI 返回 OpenAPI 文档
+func OpenAPI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", Spec)
+	}
+}
+
+// SwaggerUI 返回加载 /openapi.json 的 Swagger UI 页面
+func SwaggerUI() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", swaggerHTML)
+	}
+}
+
+// SwaggerAsset 返回 Swagger UI 页面引用的脚本与样式
+func SwaggerAsset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := path.Base(c.Param("file"))
+		data, err := swaggerAssets.ReadFile("swagger-ui/" + name)
+		if err != nil {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Header("Cache-Control", "public, max-age=86400")
+		c.Data(http.StatusOK, assetTypes[path.Ext(name)], data)
+	}
+}
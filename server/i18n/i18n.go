@@ -0,0 +1,94 @@
+package i18n
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	LangZhCN = "zh-CN"
+	LangEn   = "en"
+
+	// DefaultLang 界面以中文为主，无法识别语言时使用中文
+	DefaultLang = LangZhCN
+)
+
+//go:embed locales/*.json
+var localeFS embed.FS
+
+// catalogs 语言 -> 消息键 -> 文案，消息键为错误码或 "通知码.title/body"
+var catalogs = map[string]map[string]string{}
+
+func init() {
+	for _, lang := range []string{LangZhCN, LangEn} {
+		data, err := localeFS.ReadFile("locales/" + lang + ".json")
+		if err != nil {
+			panic(err)
+		}
+		catalog := map[string]string{}
+		if err := json.Unmarshal(data, &catalog); err != nil {
+			panic("i18n: invalid catalog " + lang + ": " + err.Error())
+		}
+		catalogs[lang] = catalog
+	}
+}
+
+// Normalize 将语言标签归一化为支持的语言，不支持时返回空字符串
+func Normalize(tag string) string {
+	tag = strings.ToLower(strings.TrimSpace(tag))
+	switch {
+	case strings.HasPrefix(tag, "zh"):
+		return LangZhCN
+	case strings.HasPrefix(tag, "en"):
+		return LangEn
+	}
+	return ""
+}
+
+// FromAcceptLanguage 按 Accept-Language 中出现的顺序选取第一个支持的语言
+func FromAcceptLanguage(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.SplitN(part, ";", 2)[0]
+		if lang := Normalize(tag); lang != "" {
+			return lang
+		}
+	}
+	return ""
+}
+
+// Lang 返回当前请求使用的语言
+func Lang(c *gin.Context) string {
+	if lang := c.GetString("lang"); lang != "" {
+		return lang
+	}
+	return DefaultLang
+}
+
+// T 查找 key 在 lang 下的文案，缺失时依次回退到默认语言与 fallback
+func T(lang, key, fallback string) string {
+	if msg, ok := catalogs[lang][key]; ok {
+		return msg
+	}
+	if msg, ok := catalogs[DefaultLang][key]; ok {
+		return msg
+	}
+	return fallback
+}
+
+// Render 查找文案并用 params 渲染模板变量，如 {{.PickupCode}}
+func Render(lang, key string, params map[string]any) (string, error) {
+	tmpl, err := template.New(key).Option("missingkey=zero").Parse(T(lang, key, key))
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, params); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
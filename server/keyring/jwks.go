@@ -0,0 +1,100 @@
+package keyring
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+)
+
+// JWK 单个公钥（RFC 7517）
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyId     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// OKP (Ed25519)
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKSet /.well-known/jwks.json 的响应体
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+var b64 = base64.RawURLEncoding
+
+// JWKS 返回所有未过期的公钥，包括提前公布、尚未开始签名的密钥。k 为 nil 时返回空集合
+func (k *Keyring) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	if k == nil {
+		return set
+	}
+
+	now := time.Now()
+	k.mu.RLock()
+	keys := make([]*key, 0, len(k.keys))
+	for _, kk := range k.keys {
+		if kk.expiresAt == nil || kk.expiresAt.After(now) {
+			keys = append(keys, kk)
+		}
+	}
+	k.mu.RUnlock()
+
+	// 最新的密钥排在前面
+	sort.Slice(keys, func(i, j int) bool { return keys[i].activatesAt.After(keys[j].activatesAt) })
+	for _, kk := range keys {
+		jwk, err := publicJWK(kk.private.Public())
+		if err != nil {
+			continue
+		}
+		jwk.KeyId = kk.id
+		jwk.Use = "sig"
+		jwk.Algorithm = kk.method.Alg()
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func publicJWK(pub crypto.PublicKey) (JWK, error) {
+	switch p := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{KeyType: "RSA", N: b64.EncodeToString(p.N.Bytes()), E: b64.EncodeToString(big.NewInt(int64(p.E)).Bytes())}, nil
+	case ed25519.PublicKey:
+		return JWK{KeyType: "OKP", Curve: "Ed25519", X: b64.EncodeToString(p)}, nil
+	default:
+		return JWK{}, fmt.Errorf("keyring: unsupported public key type %T", pub)
+	}
+}
+
+// thumbprint 按 RFC 7638 计算公钥指纹作为 kid，同一把密钥在任何实例上得到的 kid 相同
+func thumbprint(pub crypto.PublicKey) (string, error) {
+	jwk, err := publicJWK(pub)
+	if err != nil {
+		return "", err
+	}
+	// 必需成员按字典序排列，json.Marshal 对 map 的键同样按字典序输出
+	var members map[string]string
+	switch jwk.KeyType {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.KeyType, "n": jwk.N}
+	default:
+		members = map[string]string{"crv": jwk.Curve, "kty": jwk.KeyType, "x": jwk.X}
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return b64.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,315 @@
+// Package keyring 管理 JWT 非对称签名密钥：密钥保存在数据库中供多实例共享，按计划轮换，并通过 JWKS 公布公钥
+package keyring
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+)
+
+// rotationLockKey 轮换时持有的 PostgreSQL advisory lock，多实例同时轮换时只有一个实例会生成新密钥
+const rotationLockKey = 0x5041434b4b455953 // "PACKKEYS"
+
+// reloadInterval 遇到未知 kid 时从数据库重新加载的最小间隔，防止伪造 kid 的请求反复查库
+const reloadInterval = 10 * time.Second
+
+var (
+	ErrNoSigningKey = errors.New("keyring: no active signing key")
+	ErrUnknownKey   = errors.New("keyring: unknown or expired key")
+)
+
+type key struct {
+	id          string
+	method      jwt.SigningMethod
+	private     crypto.Signer
+	activatesAt time.Time
+	expiresAt   *time.Time
+}
+
+// Keyring 签名密钥的内存缓存。签名使用已生效的最新密钥，验证接受所有未过期的密钥
+type Keyring struct {
+	db  *gorm.DB
+	cfg models.JWTConfig
+	kek []byte
+
+	mu       sync.RWMutex
+	keys     map[string]*key
+	loadedAt time.Time
+}
+
+// New 加载数据库中的密钥，没有可用密钥或已到轮换时间时立即生成
+func New(ctx context.Context, db *gorm.DB, cfg models.JWTConfig) (*Keyring, error) {
+	if _, err := signingMethod(cfg.Algorithm); err != nil {
+		return nil, err
+	}
+	kek, err := cfg.KEK()
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{db: db, cfg: cfg, kek: kek, keys: make(map[string]*key)}
+	if err := k.Rotate(ctx); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Config 返回 JWT 配置
+func (k *Keyring) Config() models.JWTConfig {
+	return k.cfg
+}
+
+// overlap 旧密钥停止签名后继续用于验证的时长，不短于 Token 有效期，否则轮换时未过期的 Token 会失效
+func (k *Keyring) overlap() time.Duration {
+	return max(k.cfg.Overlap, k.cfg.TokenTTL())
+}
+
+// Rotate 删除已过期的密钥，并在到期或算法配置变更时生成下一把密钥，由后台任务定期调用。
+// 下一把密钥提前 PrePublish 写入并在 JWKS 中公布，当前密钥在它生效时停止签名
+func (k *Keyring) Rotate(ctx context.Context) error {
+	now := time.Now()
+	err := k.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", rotationLockKey).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("expires_at < ?", now).Delete(&models.SigningKey{}).Error; err != nil {
+			return err
+		}
+		if err := k.encryptLegacy(tx); err != nil {
+			return err
+		}
+
+		var latest models.SigningKey
+		err := tx.Order("activates_at DESC").Take(&latest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 第一把密钥立即生效，否则启动后无法签发 Token
+			return k.create(tx, now)
+		}
+		if err != nil {
+			return err
+		}
+		if !k.due(latest, now) {
+			return nil
+		}
+
+		activatesAt := now.Add(k.cfg.PrePublish)
+		expiresAt := activatesAt.Add(k.overlap())
+		if err := tx.Model(&latest).Updates(map[string]any{"retires_at": activatesAt, "expires_at": expiresAt}).Error; err != nil {
+			return err
+		}
+		return k.create(tx, activatesAt)
+	})
+	if err != nil {
+		return fmt.Errorf("keyring: rotate: %w", err)
+	}
+	return k.Load(ctx)
+}
+
+// due 最新的密钥是否需要被取代。算法配置变更时立即开始轮换
+func (k *Keyring) due(latest models.SigningKey, now time.Time) bool {
+	if latest.Algorithm != k.cfg.Algorithm {
+		return true
+	}
+	if k.cfg.RotationInterval <= 0 {
+		return false
+	}
+	return !latest.ActivatesAt.Add(k.cfg.RotationInterval - k.cfg.PrePublish).After(now)
+}
+
+func (k *Keyring) create(tx *gorm.DB, activatesAt time.Time) error {
+	signer, err := generate(k.cfg.Algorithm)
+	if err != nil {
+		return err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(signer)
+	if err != nil {
+		return err
+	}
+	kid, err := thumbprint(signer.Public())
+	if err != nil {
+		return err
+	}
+	sealed, err := seal(k.kek, kid, der)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.SigningKey{
+		KeyId:       kid,
+		Algorithm:   k.cfg.Algorithm,
+		PrivateKey:  sealed,
+		Encrypted:   true,
+		ActivatesAt: activatesAt,
+	}).Error
+}
+
+// encryptLegacy 加密升级前以明文保存的私钥
+func (k *Keyring) encryptLegacy(tx *gorm.DB) error {
+	var rows []models.SigningKey
+	if err := tx.Where("encrypted = ?", false).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		sealed, err := seal(k.kek, row.KeyId, row.PrivateKey)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&row).Updates(map[string]any{"private_key": sealed, "encrypted": true}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Load 从数据库重新加载未过期的密钥，其他实例轮换生成的密钥由此同步
+func (k *Keyring) Load(ctx context.Context) error {
+	now := time.Now()
+	var rows []models.SigningKey
+	if err := k.db.WithContext(ctx).Where("expires_at IS NULL OR expires_at > ?", now).Find(&rows).Error; err != nil {
+		return fmt.Errorf("keyring: load: %w", err)
+	}
+
+	keys := make(map[string]*key, len(rows))
+	for _, row := range rows {
+		method, err := signingMethod(row.Algorithm)
+		if err != nil {
+			return fmt.Errorf("keyring: key %s: %w", row.KeyId, err)
+		}
+		der := row.PrivateKey
+		if row.Encrypted {
+			if der, err = open(k.kek, row.KeyId, der); err != nil {
+				return fmt.Errorf("keyring: key %s: %w", row.KeyId, err)
+			}
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return fmt.Errorf("keyring: key %s: %w", row.KeyId, err)
+		}
+		signer, ok := parsed.(crypto.Signer)
+		if !ok {
+			return fmt.Errorf("keyring: key %s: unsupported key type %T", row.KeyId, parsed)
+		}
+		keys[row.KeyId] = &key{id: row.KeyId, method: method, private: signer, activatesAt: row.ActivatesAt, expiresAt: row.ExpiresAt}
+	}
+
+	k.mu.Lock()
+	k.keys = keys
+	k.loadedAt = now
+	k.mu.Unlock()
+	return nil
+}
+
+// current 已生效的最新密钥
+func (k *Keyring) current(now time.Time) *key {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	var cur *key
+	for _, kk := range k.keys {
+		if kk.activatesAt.After(now) {
+			continue
+		}
+		if cur == nil || kk.activatesAt.After(cur.activatesAt) {
+			cur = kk
+		}
+	}
+	return cur
+}
+
+// lookup 按 kid 查找未过期的密钥，缓存中没有时按 reloadInterval 节流后从数据库重新加载一次
+func (k *Keyring) lookup(kid string) *key {
+	k.mu.RLock()
+	kk, ok := k.keys[kid]
+	stale := time.Since(k.loadedAt) > reloadInterval
+	k.mu.RUnlock()
+
+	if !ok && stale {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := k.Load(ctx); err == nil {
+			k.mu.RLock()
+			kk, ok = k.keys[kid]
+			k.mu.RUnlock()
+		}
+	}
+	if !ok || (kk.expiresAt != nil && !kk.expiresAt.After(time.Now())) {
+		return nil
+	}
+	return kk
+}
+
+// Sign 用当前密钥签名，Token 头部带上 kid
+func (k *Keyring) Sign(claims jwt.Claims) (string, error) {
+	cur := k.current(time.Now())
+	if cur == nil {
+		return "", ErrNoSigningKey
+	}
+	token := jwt.NewWithClaims(cur.method, claims)
+	token.Header["kid"] = cur.id
+	return token.SignedString(cur.private)
+}
+
+// Parse 校验 Token 签名与有效期并解析到 claims。按头部 kid 选择公钥；
+// 没有 kid 的 HS256 Token 为升级前签发，配置了 Secret 时仍然接受
+func (k *Keyring) Parse(tokenString string, claims jwt.Claims) error {
+	methods := []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+	if k.cfg.Secret != "" {
+		methods = append(methods, jwt.SigningMethodHS256.Alg())
+	}
+	token, err := jwt.ParseWithClaims(tokenString, claims, k.keyfunc, jwt.WithValidMethods(methods))
+	if err != nil {
+		return err
+	}
+	if !token.Valid {
+		return jwt.ErrTokenSignatureInvalid
+	}
+	return nil
+}
+
+func (k *Keyring) keyfunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if token.Method.Alg() == jwt.SigningMethodHS256.Alg() && k.cfg.Secret != "" {
+			return []byte(k.cfg.Secret), nil
+		}
+		return nil, ErrUnknownKey
+	}
+
+	kk := k.lookup(kid)
+	// 算法必须与密钥一致，防止用公钥冒充 HMAC 密钥等算法混淆攻击
+	if kk == nil || kk.method.Alg() != token.Method.Alg() {
+		return nil, ErrUnknownKey
+	}
+	return kk.private.Public(), nil
+}
+
+func signingMethod(alg string) (jwt.SigningMethod, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		return jwt.SigningMethodRS256, nil
+	case jwt.SigningMethodEdDSA.Alg():
+		return jwt.SigningMethodEdDSA, nil
+	default:
+		return nil, fmt.Errorf("keyring: unsupported algorithm %q, expected RS256 or EdDSA", alg)
+	}
+}
+
+func generate(alg string) (crypto.Signer, error) {
+	switch alg {
+	case jwt.SigningMethodRS256.Alg():
+		return rsa.GenerateKey(rand.Reader, 2048)
+	case jwt.SigningMethodEdDSA.Alg():
+		_, priv, err := ed25519.GenerateKey(rand.Reader)
+		return priv, err
+	default:
+		return nil, fmt.Errorf("keyring: unsupported algorithm %q", alg)
+	}
+}
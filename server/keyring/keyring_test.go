@@ -0,0 +1,269 @@
+package keyring
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/yurin-kami/PackChann/models"
+)
+
+func TestDue(t *testing.T) {
+	activated := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cfg := models.JWTConfig{Algorithm: "EdDSA", RotationInterval: 30 * 24 * time.Hour, PrePublish: 24 * time.Hour}
+	// 下一把密钥应在 ActivatesAt + RotationInterval - PrePublish 时生成
+	publishAt := activated.Add(29 * 24 * time.Hour)
+
+	cases := []struct {
+		name string
+		cfg  models.JWTConfig
+		alg  string
+		now  time.Time
+		want bool
+	}{
+		{"刚生效", cfg, "EdDSA", activated, false},
+		{"提前公布前一秒", cfg, "EdDSA", publishAt.Add(-time.Second), false},
+		{"恰好到提前公布时间", cfg, "EdDSA", publishAt, true},
+		{"已过轮换时间", cfg, "EdDSA", activated.Add(31 * 24 * time.Hour), true},
+		{"算法配置变更", cfg, "RS256", activated, true},
+		{"未启用轮换", models.JWTConfig{Algorithm: "EdDSA"}, "EdDSA", activated.Add(365 * 24 * time.Hour), false},
+		{"未启用轮换但算法变更", models.JWTConfig{Algorithm: "EdDSA"}, "RS256", activated, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			k := &Keyring{cfg: tc.cfg}
+			latest := models.SigningKey{Algorithm: tc.alg, ActivatesAt: activated}
+			if got := k.due(latest, tc.now); got != tc.want {
+				t.Errorf("due() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// testKeyring 构造只含内存密钥的 Keyring，loadedAt 为当前时间，lookup 不会访问数据库
+func testKeyring(t *testing.T, secret string) (*Keyring, *key, *key) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	expired := now.Add(-time.Minute)
+	rs := &key{id: "rsa", method: jwt.SigningMethodRS256, private: rsaKey, activatesAt: now.Add(-time.Hour)}
+	ed := &key{id: "ed", method: jwt.SigningMethodEdDSA, private: edKey, activatesAt: now.Add(-2 * time.Hour)}
+	old := &key{id: "old", method: jwt.SigningMethodEdDSA, private: edKey, activatesAt: now.Add(-48 * time.Hour), expiresAt: &expired}
+	k := &Keyring{
+		cfg:      models.JWTConfig{Secret: secret, Algorithm: "RS256", ExpirationHours: 1},
+		keys:     map[string]*key{rs.id: rs, ed.id: ed, old.id: old},
+		loadedAt: now,
+	}
+	return k, rs, ed
+}
+
+func TestKeyfunc(t *testing.T) {
+	k, rs, ed := testKeyring(t, "legacy-secret")
+	noSecret, _, _ := testKeyring(t, "")
+
+	cases := []struct {
+		name    string
+		k       *Keyring
+		method  jwt.SigningMethod
+		kid     string
+		want    crypto.PublicKey
+		wantErr bool
+	}{
+		{"RS256 密钥", k, jwt.SigningMethodRS256, "rsa", rs.private.Public(), false},
+		{"EdDSA 密钥", k, jwt.SigningMethodEdDSA, "ed", ed.private.Public(), false},
+		{"RSA 公钥冒充 HMAC 密钥", k, jwt.SigningMethodHS256, "rsa", nil, true},
+		{"RSA kid 配 EdDSA 头部", k, jwt.SigningMethodEdDSA, "rsa", nil, true},
+		{"Ed25519 kid 配 RS256 头部", k, jwt.SigningMethodRS256, "ed", nil, true},
+		{"未知 kid", k, jwt.SigningMethodRS256, "missing", nil, true},
+		{"已过期 kid", k, jwt.SigningMethodEdDSA, "old", nil, true},
+		{"无 kid 的 RS256", k, jwt.SigningMethodRS256, "", nil, true},
+		{"无 kid 的 HS256 但未配置 Secret", noSecret, jwt.SigningMethodHS256, "", nil, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			token := jwt.New(tc.method)
+			if tc.kid != "" {
+				token.Header["kid"] = tc.kid
+			}
+			got, err := tc.k.keyfunc(token)
+			if tc.wantErr {
+				if !errors.Is(err, ErrUnknownKey) {
+					t.Fatalf("keyfunc() error = %v, want ErrUnknownKey", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("keyfunc() error = %v", err)
+			}
+			pub, ok := got.(interface{ Equal(crypto.PublicKey) bool })
+			if !ok || !pub.Equal(tc.want) {
+				t.Errorf("keyfunc() = %T, want public key of kid %q", got, tc.kid)
+			}
+		})
+	}
+
+	t.Run("无 kid 的 HS256 使用旧 Secret", func(t *testing.T) {
+		got, err := k.keyfunc(jwt.New(jwt.SigningMethodHS256))
+		if err != nil {
+			t.Fatalf("keyfunc() error = %v", err)
+		}
+		if secret, ok := got.([]byte); !ok || string(secret) != "legacy-secret" {
+			t.Errorf("keyfunc() = %v, want legacy secret", got)
+		}
+	})
+}
+
+func TestSignParse(t *testing.T) {
+	k, rs, _ := testKeyring(t, "legacy-secret")
+	claims := jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}
+
+	t.Run("使用最新生效的密钥签名", func(t *testing.T) {
+		signed, err := k.Sign(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got jwt.RegisteredClaims
+		if err := k.Parse(signed, &got); err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		token, _, err := jwt.NewParser().ParseUnverified(signed, &jwt.RegisteredClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if kid := token.Header["kid"]; kid != rs.id {
+			t.Errorf("kid = %v, want %q", kid, rs.id)
+		}
+	})
+
+	t.Run("旧 Secret 签名的 HS256 Token", func(t *testing.T) {
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k.Parse(signed, &jwt.RegisteredClaims{}); err != nil {
+			t.Errorf("Parse() error = %v", err)
+		}
+	})
+
+	t.Run("用公钥作为 HMAC 密钥伪造", func(t *testing.T) {
+		der, err := x509.MarshalPKIXPublicKey(rs.private.Public())
+		if err != nil {
+			t.Fatal(err)
+		}
+		forged := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		forged.Header["kid"] = rs.id
+		signed, err := forged.SignedString(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := k.Parse(signed, &jwt.RegisteredClaims{}); err == nil {
+			t.Error("Parse() accepted HS256 token signed with RSA public key")
+		}
+	})
+
+	t.Run("未配置 Secret 时拒绝 HS256", func(t *testing.T) {
+		noSecret, _, _ := testKeyring(t, "")
+		signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte("legacy-secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := noSecret.Parse(signed, &jwt.RegisteredClaims{}); err == nil {
+			t.Error("Parse() accepted HS256 token without configured secret")
+		}
+	})
+
+	t.Run("尚未生效的密钥不用于签名", func(t *testing.T) {
+		next := &key{id: "next", method: jwt.SigningMethodEdDSA, private: rs.private, activatesAt: time.Now().Add(time.Hour)}
+		k.keys[next.id] = next
+		defer delete(k.keys, next.id)
+		if cur := k.current(time.Now()); cur == nil || cur.id != rs.id {
+			t.Errorf("current() = %v, want %q", cur, rs.id)
+		}
+	})
+}
+
+func TestThumbprint(t *testing.T) {
+	t.Run("RFC 7638 3.1 RSA", func(t *testing.T) {
+		n, err := b64.DecodeString("0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMstn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw")
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: 65537}
+		got, err := thumbprint(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs"; got != want {
+			t.Errorf("thumbprint() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("RFC 8037 A.3 Ed25519", func(t *testing.T) {
+		x, err := b64.DecodeString("11qYAYKxCrfVS_7TyWQHOg7hcvPapiMlrwIaaPcHURo")
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := thumbprint(ed25519.PublicKey(x))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := "kPrK_qmxVWaYVA9wwBF6Iuo3vVzz7TxHCTwXBygrS4k"; got != want {
+			t.Errorf("thumbprint() = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("不支持的密钥类型", func(t *testing.T) {
+		if _, err := thumbprint("not a key"); err == nil {
+			t.Error("thumbprint() accepted unsupported key type")
+		}
+	})
+}
+
+func TestSeal(t *testing.T) {
+	kek := make([]byte, 32)
+	if _, err := rand.Read(kek); err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("pkcs8 der")
+	sealed, err := seal(kek, "kid-1", plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Fatal("sealed key contains plaintext")
+	}
+
+	got, err := open(kek, "kid-1", sealed)
+	if err != nil {
+		t.Fatalf("open() error = %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Errorf("open() = %q, want %q", got, plaintext)
+	}
+
+	otherKEK := make([]byte, 32)
+	if _, err := open(otherKEK, "kid-1", sealed); !errors.Is(err, errDecrypt) {
+		t.Errorf("open() with wrong KEK error = %v, want errDecrypt", err)
+	}
+	if _, err := open(kek, "kid-2", sealed); !errors.Is(err, errDecrypt) {
+		t.Errorf("open() with other kid error = %v, want errDecrypt", err)
+	}
+	if _, err := open(kek, "kid-1", sealed[:4]); !errors.Is(err, errDecrypt) {
+		t.Errorf("open() truncated error = %v, want errDecrypt", err)
+	}
+}
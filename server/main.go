@@ -2,20 +2,40 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yurin-kami/PackChann/database"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/metrics"
 	"github.com/yurin-kami/PackChann/middlewares"
 	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
+	"github.com/yurin-kami/PackChann/overdue"
+	"github.com/yurin-kami/PackChann/privacy"
+	"github.com/yurin-kami/PackChann/ratelimit"
+	"github.com/yurin-kami/PackChann/retention"
 	"github.com/yurin-kami/PackChann/routes"
+	"github.com/yurin-kami/PackChann/sso"
+	"github.com/yurin-kami/PackChann/tracing"
 	"github.com/yurin-kami/PackChann/utils"
+	"github.com/yurin-kami/PackChann/workers"
 )
 
 func main() {
 	fmt.Println("PackChann System On~")
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// 0. 初始化 Snowflake
 	if err := utils.InitSnowflake(1); err != nil {
 		log.Fatalf("无法初始化 Snowflake: %v", err)
@@ -27,23 +47,207 @@ func main() {
 		log.Fatalf("无法加载配置: %v", err)
 	}
 
+	// 日志：log 包的输出也统一转为结构化日志
+	logger := utils.NewLogger(cfg.Log)
+	slog.SetDefault(logger)
+
+	// 链路追踪
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing)
+	if err != nil {
+		log.Fatalf("无法初始化链路追踪: %v", err)
+	}
+
 	// 2. 连接数据库
-	db, err := database.NewConnection(context.Background(), cfg.Database)
+	db, err := database.NewConnection(context.Background(), cfg.Database, logger)
 	if err != nil {
 		log.Fatalf("无法连接数据库: %v", err)
 	}
 
-	router := gin.Default()
+	if cfg.Tracing.Enabled {
+		if err := tracing.InstrumentDB(db); err != nil {
+			log.Fatalf("无法为数据库启用链路追踪: %v", err)
+		}
+	}
+
+	// 通知渠道，log 只写日志，不能用于发送找回密码验证码
+	if err := cfg.Notify.Validate(); err != nil {
+		log.Fatalf("通知配置错误: %v", err)
+	}
+	sender, err := notify.NewSender(cfg.Notify, logger)
+	if err != nil {
+		log.Fatalf("通知配置错误: %v", err)
+	}
+	notifier := notify.NewNotifier(sender)
+
+	// 后台任务
+	manager := workers.NewManager(context.Background())
+
+	// 登录/注册限流，单实例使用内存存储；多实例部署需换成共享存储
+	limitStore := ratelimit.NewMemoryStore()
+	limiter := ratelimit.New(limitStore, cfg.RateLimit)
+	manager.Every("ratelimit-sweep", time.Minute, func(context.Context) { limitStore.Sweep() })
+
+	// Token 签名密钥，启动时没有可用密钥会立即生成；之后定期检查是否需要轮换，并同步其他实例生成的密钥
+	keys, err := keyring.New(context.Background(), db, cfg.JWT)
+	if err != nil {
+		log.Fatalf("无法初始化签名密钥: %v", err)
+	}
+	manager.Every("jwt-key-rotation", time.Minute, func(ctx context.Context) {
+		if err := keys.Rotate(ctx); err != nil {
+			logger.Error("签名密钥轮换失败", "error", err)
+		}
+	})
+
+	// 注销冷静期结束的账号匿名化
+	manager.Every("account-anonymization", time.Hour, func(ctx context.Context) {
+		n, err := privacy.AnonymizeDue(ctx, db, time.Now())
+		if err != nil {
+			logger.Error("账号匿名化失败", "error", err)
+		}
+		if n > 0 {
+			logger.Info("已匿名化注销账号", "count", n)
+		}
+	})
+
+	// 逾期未取包裹逐级提醒
+	if cfg.Overdue.Enabled {
+		manager.Every("overdue-scan", cfg.Overdue.Interval, func(ctx context.Context) {
+			counts, err := overdue.Scan(ctx, db, notifier, cfg.Overdue, time.Now())
+			if err != nil {
+				logger.Error("逾期包裹扫描失败", "error", err)
+			}
+			if counts[models.OverdueRemind]+counts[models.OverdueWarn]+counts[models.OverdueReturn] > 0 {
+				logger.Info("逾期包裹扫描", "remind", counts[models.OverdueRemind], "warn", counts[models.OverdueWarn], "return", counts[models.OverdueReturn])
+			}
+		})
+	}
+
+	// 数据保留：按规则归档后匿名化或删除过期数据，dry_run 时只记录将被处理的数量
+	if err := retention.Validate(cfg.Retention.Rules); err != nil {
+		log.Fatalf("数据保留规则配置错误: %v", err)
+	}
+	if cfg.Retention.Enabled {
+		manager.Every("data-retention", cfg.Retention.Interval, func(ctx context.Context) {
+			results, err := retention.Run(ctx, db, cfg.Retention, time.Now(), cfg.Retention.DryRun)
+			for _, r := range results {
+				logger.Info("数据保留", "rule", r.Rule, "dry_run", cfg.Retention.DryRun, "matched", r.Matched, "processed", r.Processed, "archive", r.Archive)
+			}
+			if err != nil {
+				logger.Error("数据保留任务失败", "error", err)
+			}
+			if cfg.Retention.DryRun {
+				return
+			}
+			removed, err := retention.Prune(cfg.Retention.ArchiveDir, cfg.Retention.ArchiveRetention, time.Now())
+			if len(removed) > 0 {
+				logger.Info("删除过期归档", "files", removed)
+			}
+			if err != nil {
+				logger.Error("删除过期归档失败", "error", err)
+			}
+		})
+	}
+
+	// 校园统一身份认证
+	ssoProviders, err := sso.NewRegistry(cfg.SSO.Providers)
+	if err != nil {
+		log.Fatalf("统一身份认证配置错误: %v", err)
+	}
+
+	router := gin.New()
+	// 让 c 作为 context 使用时能取到 request 上的 span，gorm 查询才能挂到请求 span 下
+	router.ContextWithFallback = true
+	if err := router.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("可信代理配置错误: %v", err)
+	}
+
+	// 链路追踪放在最外层，访问日志才能带上 trace_id
+	if cfg.Tracing.Enabled {
+		router.Use(tracing.Middleware(cfg.Tracing))
+	}
+
+	// 请求 ID、结构化访问日志与 panic 恢复
+	router.Use(middlewares.RequestIDMiddleware(), middlewares.LoggerMiddleware(logger), middlewares.RecoveryMiddleware())
+
+	// 响应语言
+	router.Use(middlewares.LocaleMiddleware())
 
 	// 添加 CORS 中间件
 	router.Use(middlewares.CORSMiddleware())
 
+	// Prometheus 指标
+	var metricsSrv *http.Server
+	if err := cfg.Metrics.Validate(); err != nil {
+		log.Fatalf("指标配置错误: %v", err)
+	}
+	if cfg.Metrics.Enabled {
+		metrics.Register(db, cfg.Overdue)
+		router.Use(metrics.Middleware())
+
+		if cfg.Metrics.ListenAddr != "" {
+			metricsRouter := gin.New()
+			metricsRouter.GET(cfg.Metrics.Path, metrics.Handler(cfg.Metrics))
+			metricsSrv = &http.Server{Addr: cfg.Metrics.ListenAddr, Handler: metricsRouter, ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout}
+			go func() {
+				if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("指标服务异常退出", "addr", cfg.Metrics.ListenAddr, "error", err)
+					os.Exit(1)
+				}
+			}()
+		} else {
+			router.GET(cfg.Metrics.Path, metrics.Handler(cfg.Metrics))
+		}
+	}
+
 	// 3. 注册路由
-	// 未受保护路由 (登录/注册)
-	routes.UnprotectedRoutes(db, router, cfg.JWT)
+	routes.RegisterAll(db, router, cfg, keys, notifier, manager, limiter, ssoProviders)
+
+	// 4. 启动 HTTP 服务
+	srv := &http.Server{
+		Addr:              cfg.Server.Addr,
+		Handler:           router,
+		ReadTimeout:       cfg.Server.ReadTimeout,
+		ReadHeaderTimeout: cfg.Server.ReadHeaderTimeout,
+		WriteTimeout:      cfg.Server.WriteTimeout,
+		IdleTimeout:       cfg.Server.IdleTimeout,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Error("HTTP 服务异常退出", "addr", cfg.Server.Addr, "error", err)
+			os.Exit(1)
+		}
+	}()
+	utils.SetReady(true)
+
+	// 5. 收到退出信号后优雅关闭
+	<-ctx.Done()
+	stop()
+	logger.Info("收到退出信号，开始优雅关闭", "drain_delay", cfg.Server.DrainDelay, "shutdown_timeout", cfg.Server.ShutdownTimeout)
+
+	// 先标记为未就绪，等待负载均衡摘除流量后再开始排空请求
+	utils.SetReady(false)
+	time.Sleep(cfg.Server.DrainDelay)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.Server.ShutdownTimeout)
+	defer cancel()
 
-	// 受保护路由 (业务逻辑)
-	routes.ProtectedRoutes(db, router, cfg.JWT)
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		logger.Error("HTTP 服务关闭失败", "error", err)
+	}
+	if metricsSrv != nil {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			logger.Error("指标服务关闭失败", "error", err)
+		}
+	}
+	if err := manager.Stop(shutdownCtx); err != nil {
+		logger.Error("后台任务未能及时退出", "workers", manager.Status(), "error", err)
+	}
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		logger.Error("链路追踪数据刷新失败", "error", err)
+	}
+	if err := database.Close(); err != nil {
+		logger.Error("关闭数据库连接池失败", "error", err)
+	}
 
-	router.Run(":8088")
+	logger.Info("PackChann System Off~")
 }
@@ -0,0 +1,65 @@
+package mfa_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/yurin-kami/PackChann/mfa"
+)
+
+// RFC 6238 附录 B 的 SHA-1 测试密钥 "12345678901234567890"，取 8 位验证码的后 6 位
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerify(t *testing.T) {
+	cases := []struct {
+		name     string
+		code     string
+		lastStep int64
+		now      int64
+		wantStep int64
+		wantOK   bool
+	}{
+		{"RFC 向量 T=59", "287082", 0, 59, 1, true},
+		{"RFC 向量 T=1111111109", "081804", 0, 1111111109, 37037036, true},
+		{"前后空白被忽略", " 287082 ", 0, 59, 1, true},
+		{"服务器慢一个时间步", "287082", 0, 29, 1, true},
+		{"服务器快一个时间步", "287082", 0, 89, 1, true},
+		{"超出允许的偏差", "287082", 0, 119, 0, false},
+		{"同一时间步不能重复使用", "287082", 1, 59, 0, false},
+		{"更早的时间步不能使用", "287082", 2, 89, 0, false},
+		{"错误的验证码", "287083", 0, 59, 0, false},
+		{"位数不足", "28708", 0, 59, 0, false},
+		{"位数过多", "2870820", 0, 59, 0, false},
+		{"8 位完整验证码", "94287082", 0, 59, 0, false},
+		{"非数字", "abcdef", 0, 59, 0, false},
+		{"空验证码", "", 0, 59, 0, false},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := mfa.Verify(rfcSecret, tc.code, tc.lastStep, time.Unix(tc.now, 0))
+			if ok != tc.wantOK || step != tc.wantStep {
+				t.Errorf("Verify(%q, lastStep=%d, t=%d) = (%d, %v), want (%d, %v)", tc.code, tc.lastStep, tc.now, step, ok, tc.wantStep, tc.wantOK)
+			}
+		})
+	}
+}
+
+// 验证成功后把返回的时间步作为 lastStep，同一验证码在有效期内再次提交会被拒绝
+func TestVerifyRejectsReplay(t *testing.T) {
+	now := time.Unix(59, 0)
+	step, ok := mfa.Verify(rfcSecret, "287082", 0, now)
+	if !ok {
+		t.Fatal("first use rejected")
+	}
+	for _, later := range []time.Time{now, now.Add(30 * time.Second)} {
+		if _, ok := mfa.Verify(rfcSecret, "287082", step, later); ok {
+			t.Errorf("replay accepted at %v", later.Unix())
+		}
+	}
+}
+
+func TestVerifyInvalidSecret(t *testing.T) {
+	if _, ok := mfa.Verify("not base32!", "287082", 0, time.Unix(59, 0)); ok {
+		t.Error("invalid secret accepted")
+	}
+}
@@ -0,0 +1,88 @@
+package middlewares
+
+import (
+	"net"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/utils"
+	"gorm.io/gorm"
+)
+
+// lastUsedResolution 最近使用时间的更新粒度，避免每个请求都写库
+const lastUsedResolution = time.Minute
+
+// ScopedAuthMiddleware 同时接受用户 Token 与 API Key。带 X-API-Key 头时按 API Key 认证，
+// 要求具备 scope 权限且来源地址在白名单内；否则按 AuthMiddleware 校验用户 Token
+func ScopedAuthMiddleware(db *gorm.DB, keys *keyring.Keyring, scope string) gin.HandlerFunc {
+	userAuth := AuthMiddleware(db, keys)
+	return func(c *gin.Context) {
+		raw := c.GetHeader("X-API-Key")
+		if raw == "" {
+			userAuth(c)
+			return
+		}
+
+		var key models.APIKey
+		err := db.WithContext(c).Where("key_hash = ? AND revoked_at IS NULL", utils.HashAPIKey(raw)).Take(&key).Error
+		if err != nil {
+			if err == gorm.ErrRecordNotFound {
+				apierrors.Respond(c, apierrors.ErrAPIKeyInvalid)
+				return
+			}
+			apierrors.Respond(c, apierrors.Internal(err))
+			return
+		}
+
+		now := time.Now()
+		if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+			apierrors.Respond(c, apierrors.ErrAPIKeyInvalid)
+			return
+		}
+		ip := c.ClientIP()
+		if !ipAllowed(key.AllowedIPs, ip) {
+			apierrors.Respond(c, apierrors.ErrAPIKeyIPNotAllowed)
+			return
+		}
+		if !slices.Contains(key.Scopes, scope) {
+			apierrors.Respond(c, apierrors.ErrInsufficientScope)
+			return
+		}
+
+		err = db.WithContext(c).Model(&models.APIKey{}).
+			Where("key_id = ? AND (last_used_at IS NULL OR last_used_at < ?)", key.KeyId, now.Add(-lastUsedResolution)).
+			Updates(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error
+		if err != nil {
+			utils.Logger(c).Warn("failed to record api key usage", "key_id", key.KeyId, "error", err)
+		}
+
+		c.Set("api_key_id", key.KeyId)
+		c.Set("role", "api_key")
+		c.Next()
+	}
+}
+
+// ipAllowed 白名单为空时不限制，条目可以是单个 IP 或 CIDR
+func ipAllowed(allowed []string, clientIP string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	ip := net.ParseIP(clientIP)
+	if ip == nil {
+		return false
+	}
+	for _, entry := range allowed {
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowedIP := net.ParseIP(entry); allowedIP != nil && allowedIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
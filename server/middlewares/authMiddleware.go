@@ -1,65 +1,67 @@
 package middlewares
 
 import (
-	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/i18n"
+	"github.com/yurin-kami/PackChann/keyring"
 	"github.com/yurin-kami/PackChann/models"
 	"github.com/yurin-kami/PackChann/utils"
 	"gorm.io/gorm"
 )
 
-func AuthMiddleware(db *gorm.DB, jwtCfg models.JWTConfig) gin.HandlerFunc {
+func AuthMiddleware(db *gorm.DB, keys *keyring.Keyring) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
-			c.Abort()
+			apierrors.Respond(c, apierrors.ErrUnauthorized)
 			return
 		}
 
 		// 格式通常为 "Bearer <token>"
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || parts[0] != "Bearer" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header format must be Bearer {token}"})
-			c.Abort()
+			apierrors.Respond(c, apierrors.ErrUnauthorized)
 			return
 		}
 
 		tokenString := parts[1]
 
-		// 解析 Token
-		token, err := jwt.ParseWithClaims(tokenString, &utils.Claims{}, func(token *jwt.Token) (interface{}, error) {
-			return []byte(jwtCfg.Secret), nil
-		})
-
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
-			c.Abort()
+		// 解析 Token，接受任何未过期的签名密钥
+		claims := &utils.Claims{}
+		if err := keys.Parse(tokenString, claims); err != nil {
+			apierrors.Respond(c, apierrors.ErrTokenInvalid)
 			return
 		}
 
-		claims, ok := token.Claims.(*utils.Claims)
-		if !ok {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token claims"})
-			c.Abort()
-			return
+		// 数据库验证 Token 可用性 (检查是否被吊销或是否存在)，顺带取出用户的语言偏好
+		var session struct {
+			TokenId     int64
+			Language    string
+			TOTPEnabled bool
 		}
-
-		// 数据库验证 Token 可用性 (检查是否被吊销或是否存在)
-		var userToken models.UserToken
-		if err := db.Where("access_token = ?", tokenString).First(&userToken).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is no longer valid"})
-			c.Abort()
+		err := db.WithContext(c).Model(&models.UserToken{}).
+			Select("user_tokens.token_id, users.language, users.totp_enabled").
+			Joins("JOIN users ON users.user_id = user_tokens.user_id AND users.deleted_at IS NULL").
+			Where("user_tokens.access_token = ?", tokenString).
+			Take(&session).Error
+		if err != nil {
+			apierrors.Respond(c, apierrors.ErrTokenInvalid)
 			return
 		}
+		if lang := i18n.Normalize(session.Language); lang != "" {
+			c.Set("lang", lang)
+			c.Header("Content-Language", lang)
+		}
 
 		// 将用户信息存入上下文
 		c.Set("user_id", claims.UserId)
 		c.Set("student_id", claims.StudentId)
 		c.Set("role", claims.Role)
+		c.Set("token_id", session.TokenId)
+		c.Set("totp_enabled", session.TOTPEnabled)
 		c.Next()
 	}
 }
@@ -68,8 +70,19 @@ func AdminMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		role, exists := c.Get("role")
 		if !exists || role != "admin" {
-			c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
-			c.Abort()
+			apierrors.Respond(c, apierrors.ErrForbidden)
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireMFAMiddleware 强制两步验证的角色未开启两步验证时拒绝访问，需放在 AuthMiddleware 之后。
+// 用于保护管理接口，避免开启强制策略前签发的 Token 绕过两步验证
+func RequireMFAMiddleware(mfaCfg models.MFAConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if mfaCfg.Requires(c.GetString("role")) && !c.GetBool("totp_enabled") {
+			apierrors.Respond(c, apierrors.ErrMFARequired)
 			return
 		}
 		c.Next()
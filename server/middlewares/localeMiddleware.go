@@ -0,0 +1,19 @@
+package middlewares
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/i18n"
+)
+
+// LocaleMiddleware 根据 Accept-Language 选择响应语言，登录用户的语言偏好会在 AuthMiddleware 中覆盖它
+func LocaleMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lang := i18n.FromAcceptLanguage(c.GetHeader("Accept-Language"))
+		if lang == "" {
+			lang = i18n.DefaultLang
+		}
+		c.Set("lang", lang)
+		c.Header("Content-Language", lang)
+		c.Next()
+	}
+}
@@ -0,0 +1,62 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/apierrors"
+	"github.com/yurin-kami/PackChann/ratelimit"
+	"github.com/yurin-kami/PackChann/utils"
+)
+
+// maxPeekBody 限流接口请求体的大小上限，这些接口未经认证，不能读取任意大小的请求体
+const maxPeekBody = 64 << 10
+
+// RateLimitMiddleware 按来源 IP 和请求体中的学号分别限流，scope 区分接口。
+// 存储故障时放行并记录日志，避免限流组件故障导致无法登录
+func RateLimitMiddleware(limiter *ratelimit.Limiter, scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ok, wait, err := limiter.AllowIP(c, scope, c.ClientIP())
+		if err == nil && ok {
+			studentId, peekErr := peekStudentId(c)
+			var tooLarge *http.MaxBytesError
+			if errors.As(peekErr, &tooLarge) {
+				apierrors.Respond(c, apierrors.ErrInvalidInput)
+				return
+			}
+			ok, wait, err = limiter.AllowAccount(c, scope, studentId)
+		}
+		if err != nil {
+			utils.Logger(c).Warn("rate limit store unavailable", "error", err)
+			c.Next()
+			return
+		}
+		if !ok {
+			c.Header("Retry-After", ratelimit.RetryAfter(wait))
+			apierrors.Respond(c, apierrors.ErrRateLimited)
+			return
+		}
+		c.Next()
+	}
+}
+
+// peekStudentId 读取请求体中的 student_id 后把请求体放回，不影响后续绑定。请求体超过 maxPeekBody 时返回 *http.MaxBytesError
+func peekStudentId(c *gin.Context) (string, error) {
+	if c.Request.Body == nil {
+		return "", nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxPeekBody))
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	var input struct {
+		StudentId string `json:"student_id"`
+	}
+	_ = json.Unmarshal(body, &input)
+	return input.StudentId, nil
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// API Key 可授予的权限，只能访问声明了对应权限的接口
+const (
+	ScopePackRead     = "pack:read"
+	ScopePackCheckIn  = "pack:checkin"
+	ScopePackCheckOut = "pack:checkout"
+	ScopePackStatus   = "pack:status"
+)
+
+// APIKey 站点设备与外部系统使用的 API Key，只保存 SHA-256 哈希，明文仅在创建时返回一次
+type APIKey struct {
+	KeyId      int64      `gorm:"primaryKey;autoIncrement" json:"key_id"`
+	Name       string     `gorm:"type:varchar(100);not null" json:"name"`
+	Prefix     string     `gorm:"type:varchar(16);not null" json:"prefix"` // 明文前缀，便于在列表中辨认
+	KeyHash    string     `gorm:"type:char(64);not null;uniqueIndex" json:"-"`
+	Scopes     []string   `gorm:"serializer:json;not null" json:"scopes"`
+	AllowedIPs []string   `gorm:"serializer:json" json:"allowed_ips"` // IP 或 CIDR，为空则不限制
+	CreatedBy  int64      `gorm:"not null" json:"created_by"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	LastUsedIP string     `gorm:"type:varchar(64)" json:"last_used_ip"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// CreateAPIKeyInput 管理员创建 API Key
+type CreateAPIKeyInput struct {
+	Name       string     `json:"name" binding:"required,max=100"`
+	Scopes     []string   `json:"scopes" binding:"required,min=1,dive,oneof=pack:read pack:checkin pack:checkout pack:status"`
+	AllowedIPs []string   `json:"allowed_ips" binding:"omitempty,dive,ip|cidr"`
+	ExpiresAt  *time.Time `json:"expires_at" binding:"omitempty,gt"`
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 审计记录的操作者类型
+const (
+	ActorUser      = "user"
+	ActorAPIKey    = "api_key"
+	ActorAnonymous = "anonymous"
+	ActorSystem    = "system"
+)
+
+// AuditLog 管理操作与敏感操作的审计记录，与被记录的变更在同一事务中写入。
+// 表只允许追加，数据库触发器会拒绝 UPDATE / DELETE。Before / After 只包含发生变化的字段
+type AuditLog struct {
+	AuditId    int64           `gorm:"primaryKey;autoIncrement" json:"audit_id"`
+	CreatedAt  time.Time       `gorm:"autoCreateTime;not null;index" json:"created_at"`
+	ActorType  string          `gorm:"type:varchar(20);not null;index:idx_audit_actor" json:"actor_type"`
+	ActorId    int64           `gorm:"not null;default:0;index:idx_audit_actor" json:"actor_id"`
+	Action     string          `gorm:"type:varchar(50);not null;index" json:"action"`
+	TargetType string          `gorm:"type:varchar(30);not null;index:idx_audit_target" json:"target_type"`
+	TargetId   string          `gorm:"type:varchar(64);not null;index:idx_audit_target" json:"target_id"`
+	Before     json.RawMessage `gorm:"type:jsonb" json:"before"`
+	After      json.RawMessage `gorm:"type:jsonb" json:"after"`
+	IP         string          `gorm:"type:varchar(64)" json:"ip"`
+	UserAgent  string          `gorm:"type:varchar(255)" json:"user_agent"`
+	RequestId  string          `gorm:"type:varchar(64)" json:"request_id"`
+}
+
+func (AuditLog) TableName() string {
+	return "audit_log"
+}
+
+// AuditLogQuery 审计记录查询条件，时间范围为 [Since, Until)
+type AuditLogQuery struct {
+	PageQuery
+	ActorType  string    `form:"actor_type" binding:"omitempty,oneof=user api_key anonymous system"`
+	ActorId    int64     `form:"actor_id"`
+	Action     string    `form:"action"` // 逗号分隔，如 pack.update,pack.status
+	TargetType string    `form:"target_type"`
+	TargetId   string    `form:"target_id"`
+	Since      time.Time `form:"since"`
+	Until      time.Time `form:"until"`
+}
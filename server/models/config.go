@@ -1,31 +1,494 @@
 package models
 
 import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	Database DBConfig  `mapstructure:"database"`
-	JWT      JWTConfig `mapstructure:"jwt"`
+	Server   ServerConfig  `mapstructure:"server"`
+	API      APIConfig     `mapstructure:"api"`
+	Database DBConfig      `mapstructure:"database"`
+	JWT      JWTConfig     `mapstructure:"jwt"`
+	Metrics  MetricsConfig `mapstructure:"metrics"`
+	Log      LogConfig     `mapstructure:"log"`
+	Tracing  TracingConfig `mapstructure:"tracing"`
+
+	RateLimit  RateLimitConfig  `mapstructure:"rate_limit"`
+	Password   PasswordConfig   `mapstructure:"password"`
+	MFA        MFAConfig        `mapstructure:"mfa"`
+	SSO        SSOConfig        `mapstructure:"sso"`
+	Privacy    PrivacyConfig    `mapstructure:"privacy"`
+	Notify     NotifyConfig     `mapstructure:"notify"`
+	Retention  RetentionConfig  `mapstructure:"retention"`
+	Overdue    OverdueConfig    `mapstructure:"overdue"`
+	Returns    ReturnsConfig    `mapstructure:"returns"`
+	StorageFee StorageFeeConfig `mapstructure:"storage_fee"`
+}
+
+// LogConfig 日志级别 (debug/info/warn/error) 与输出格式 (json/text)
+type LogConfig struct {
+	Level  string `mapstructure:"level"`
+	Format string `mapstructure:"format"`
+}
+
+// ServerConfig HTTP 服务监听地址、超时与优雅关闭配置
+type ServerConfig struct {
+	Addr              string        `mapstructure:"addr"`
+	ReadTimeout       time.Duration `mapstructure:"read_timeout"`
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+	WriteTimeout      time.Duration `mapstructure:"write_timeout"`
+	IdleTimeout       time.Duration `mapstructure:"idle_timeout"`
+	// DrainDelay 收到退出信号后先标记为未就绪，等待负载均衡摘除流量的时间
+	DrainDelay      time.Duration `mapstructure:"drain_delay"`
+	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
+	// TrustedProxies 可信反向代理的 IP/CIDR，只有来自这些地址的 X-Forwarded-For 才会被采信；留空则使用连接地址
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+}
+
+// APIConfig 旧版路由的下线时间，格式为 2006-01-02，留空则不返回 Sunset 头
+type APIConfig struct {
+	LegacySunset string `mapstructure:"legacy_sunset"`
+}
+
+// Validate 检查 LegacySunset 的格式，写错时拒绝启动，而不是悄悄不返回 Sunset 头
+func (a APIConfig) Validate() error {
+	if a.LegacySunset == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.DateOnly, a.LegacySunset); err != nil {
+		return fmt.Errorf("api.legacy_sunset must be YYYY-MM-DD: %w", err)
+	}
+	return nil
+}
+
+// LegacySunsetTime 解析 LegacySunset，未配置时返回零值。格式已在 LoadConfig 中校验
+func (a APIConfig) LegacySunsetTime() time.Time {
+	t, _ := time.Parse(time.DateOnly, a.LegacySunset)
+	return t
 }
 
 type DBConfig struct {
+	Host        string `mapstructure:"host"`
+	Port        int    `mapstructure:"port"`
+	User        string `mapstructure:"user"`
+	Password    string `mapstructure:"password"`
+	DBName      string `mapstructure:"dbname"`
+	SSLMode     string `mapstructure:"sslmode"`
+	SSLRootCert string `mapstructure:"sslrootcert"`
+
+	// 连接池配置，作用于主库与所有只读副本
+	MaxOpenConns    int           `mapstructure:"max_open_conns"`
+	MaxIdleConns    int           `mapstructure:"max_idle_conns"`
+	ConnMaxLifetime time.Duration `mapstructure:"conn_max_lifetime"`
+	ConnMaxIdleTime time.Duration `mapstructure:"conn_max_idle_time"`
+
+	// Replicas 只读副本，用于管理端的大列表查询；未填写的字段沿用主库配置
+	Replicas []DBReplicaConfig `mapstructure:"replicas"`
+}
+
+type DBReplicaConfig struct {
 	Host     string `mapstructure:"host"`
 	Port     int    `mapstructure:"port"`
 	User     string `mapstructure:"user"`
 	Password string `mapstructure:"password"`
-	DBName   string `mapstructure:"dbname"`
 }
 
+// JWTConfig Token 签名配置。Token 使用 Algorithm（RS256 或 EdDSA）的非对称密钥签名，密钥按 RotationInterval 自动轮换：
+// 新密钥提前 PrePublish 在 JWKS 中公布，旧密钥停止签名后再保留 Overlap 用于验证（至少为 Token 有效期）。
+// Secret 仅用于验证升级前签发的 HS256 Token，留空则不再接受。
+// KeyEncryptionKey 为 base64 编码的 32 字节密钥，用于加密保存在数据库中的签名私钥，
+// 数据库备份与只读副本中只有密文；建议通过环境变量 PACKCHANN_JWT_KEY_ENCRYPTION_KEY 提供，不要与数据库放在一起
 type JWTConfig struct {
-	Secret          string `mapstructure:"secret"`
-	ExpirationHours int16  `mapstructure:"expiration_hours"`
+	Secret           string `mapstructure:"secret"`
+	ExpirationHours  int16  `mapstructure:"expiration_hours"`
+	KeyEncryptionKey string `mapstructure:"key_encryption_key"`
+
+	Algorithm        string        `mapstructure:"algorithm"`
+	RotationInterval time.Duration `mapstructure:"rotation_interval"`
+	PrePublish       time.Duration `mapstructure:"pre_publish"`
+	Overlap          time.Duration `mapstructure:"overlap"`
+}
+
+// KEK 解码签名私钥的加密密钥，未设置或长度不是 32 字节时返回错误
+func (j JWTConfig) KEK() ([]byte, error) {
+	if j.KeyEncryptionKey == "" {
+		return nil, errors.New("jwt.key_encryption_key is required")
+	}
+	kek, err := base64.StdEncoding.DecodeString(j.KeyEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("jwt.key_encryption_key: %w", err)
+	}
+	if len(kek) != 32 {
+		return nil, fmt.Errorf("jwt.key_encryption_key must decode to 32 bytes, got %d", len(kek))
+	}
+	return kek, nil
+}
+
+// TokenTTL 正式 Token 的有效期
+func (j JWTConfig) TokenTTL() time.Duration {
+	return time.Duration(j.ExpirationHours) * time.Hour
+}
+
+// MetricsConfig Prometheus 指标配置。ListenAddr 非空时在独立端口暴露，否则挂在主服务上并用 Token 保护
+type MetricsConfig struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Path       string `mapstructure:"path"`
+	Token      string `mapstructure:"token"`
+	ListenAddr string `mapstructure:"listen_addr"`
+}
+
+// Validate 指标包含业务数据，启用时必须设置 Token 或在独立端口暴露，不能在主服务上公开访问
+func (m MetricsConfig) Validate() error {
+	if m.Enabled && m.Token == "" && m.ListenAddr == "" {
+		return errors.New("metrics.enabled requires metrics.token or metrics.listen_addr")
+	}
+	return nil
+}
+
+// TracingConfig OpenTelemetry 链路追踪配置，Exporter 为 "otlp"（OTLP/HTTP）或 "stdout"（本地调试）
+type TracingConfig struct {
+	Enabled     bool    `mapstructure:"enabled"`
+	Exporter    string  `mapstructure:"exporter"`
+	Endpoint    string  `mapstructure:"endpoint"`
+	Insecure    bool    `mapstructure:"insecure"`
+	ServiceName string  `mapstructure:"service_name"`
+	SampleRatio float64 `mapstructure:"sample_ratio"`
+}
+
+// RateLimitConfig 登录/注册限流与账号锁定。令牌桶每分钟补充 PerMinute 个，容量为 Burst；
+// FailureWindow 内连续失败 MaxFailures 次锁定账号 LockoutDuration
+type RateLimitConfig struct {
+	Enabled          bool          `mapstructure:"enabled"`
+	IPPerMinute      float64       `mapstructure:"ip_per_minute"`
+	IPBurst          int           `mapstructure:"ip_burst"`
+	AccountPerMinute float64       `mapstructure:"account_per_minute"`
+	AccountBurst     int           `mapstructure:"account_burst"`
+	MaxFailures      int           `mapstructure:"max_failures"`
+	FailureWindow    time.Duration `mapstructure:"failure_window"`
+	LockoutDuration  time.Duration `mapstructure:"lockout_duration"`
+}
+
+// PasswordConfig 密码强度策略与找回密码验证码配置
+type PasswordConfig struct {
+	MinLength     int  `mapstructure:"min_length"`
+	RequireLetter bool `mapstructure:"require_letter"`
+	RequireDigit  bool `mapstructure:"require_digit"`
+	RequireSymbol bool `mapstructure:"require_symbol"`
+	// ResetCodeTTL 找回密码验证码有效期，ResetMaxAttempts 为单个验证码允许的错误次数
+	ResetCodeTTL     time.Duration `mapstructure:"reset_code_ttl"`
+	ResetMaxAttempts int           `mapstructure:"reset_max_attempts"`
+}
+
+// MFAConfig 两步验证配置。RequiredRoles 中的角色必须绑定验证器才能登录和访问管理接口
+type MFAConfig struct {
+	Issuer        string        `mapstructure:"issuer"`
+	RequiredRoles []string      `mapstructure:"required_roles"`
+	TokenTTL      time.Duration `mapstructure:"token_ttl"`
+	RecoveryCodes int           `mapstructure:"recovery_codes"`
+}
+
+// Requires 该角色是否强制两步验证
+func (m MFAConfig) Requires(role string) bool {
+	for _, r := range m.RequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// SSOConfig 外部身份提供方登录。完成后浏览器跳回 FrontendCallback，一次性票据放在 URL fragment 中
+type SSOConfig struct {
+	FrontendCallback string              `mapstructure:"frontend_callback"`
+	TicketTTL        time.Duration       `mapstructure:"ticket_ttl"`
+	Providers        []SSOProviderConfig `mapstructure:"providers"`
+}
+
+// SSOProviderConfig 单个身份提供方。Type 为 "oidc" 或 "cas"；
+// *Claim 对 OIDC 为 ID Token 中的 claim 名，对 CAS 为属性名（CAS 的 StudentIdClaim 为空时使用登录用户名）
+type SSOProviderConfig struct {
+	Name        string `mapstructure:"name"`
+	Type        string `mapstructure:"type"`
+	DisplayName string `mapstructure:"display_name"`
+	// RedirectURL 本服务的回调地址，即 /api/v1/auth/sso/{name}/callback 的完整 URL
+	RedirectURL string `mapstructure:"redirect_url"`
+
+	// OIDC
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client_id"`
+	ClientSecret string   `mapstructure:"client_secret"`
+	Scopes       []string `mapstructure:"scopes"`
+
+	// CAS
+	BaseURL string `mapstructure:"base_url"`
+	Version string `mapstructure:"version"`
+
+	StudentIdClaim string `mapstructure:"student_id_claim"`
+	NameClaim      string `mapstructure:"name_claim"`
+	PhoneClaim     string `mapstructure:"phone_claim"`
+	// AutoProvision 学号在本系统不存在时自动创建账号（需要 IdP 提供手机号），否则拒绝登录
+	AutoProvision bool `mapstructure:"auto_provision"`
+}
+
+// 通知渠道
+const (
+	NotifySenderLog     = "log"
+	NotifySenderWebhook = "webhook"
+)
+
+// NotifyConfig 通知渠道。Sender 为 log 时只写日志，用于本地开发，日志中不会出现验证码，找回密码不可用；
+// 为 webhook 时把通知 POST 到 WebhookURL，由短信/邮件网关投递，WebhookToken 非空时作为 Bearer Token
+type NotifyConfig struct {
+	Sender       string        `mapstructure:"sender"`
+	WebhookURL   string        `mapstructure:"webhook_url"`
+	WebhookToken string        `mapstructure:"webhook_token"`
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// Validate 检查 Sender 及其所需的配置，启动时调用
+func (n NotifyConfig) Validate() error {
+	switch n.Sender {
+	case NotifySenderLog:
+		return nil
+	case NotifySenderWebhook:
+		if n.WebhookURL == "" {
+			return errors.New("notify.sender = webhook requires notify.webhook_url")
+		}
+		return nil
+	default:
+		return fmt.Errorf("notify.sender: unknown sender %q, expected log or webhook", n.Sender)
+	}
+}
+
+// PrivacyConfig 个人信息保护。用户申请注销后经过 DeletionCoolingOff 冷静期才匿名化，期间可以撤销。
+// 既没有密码也没有两步验证的账号，只能在登录后 ReauthWindow 内申请注销
+type PrivacyConfig struct {
+	DeletionCoolingOff time.Duration `mapstructure:"deletion_cooling_off"`
+	ReauthWindow       time.Duration `mapstructure:"reauth_window"`
+}
+
+// RetentionConfig 数据保留策略。后台任务每隔 Interval 执行一次 Rules，处理前先把被删除的记录或被匿名化的列写入
+// ArchiveDir 下的 JSONL.gz 归档；DryRun 为 true 时只统计并记录将被处理的数量，不修改数据。
+// 归档中仍有个人信息，超过 ArchiveRetention 的归档文件会被删除，为 0 时永久保留（被清理的数据并未真正消失，不建议）
+type RetentionConfig struct {
+	Enabled          bool            `mapstructure:"enabled"`
+	DryRun           bool            `mapstructure:"dry_run"`
+	Interval         time.Duration   `mapstructure:"interval"`
+	ArchiveDir       string          `mapstructure:"archive_dir"`
+	ArchiveRetention time.Duration   `mapstructure:"archive_retention"`
+	BatchSize        int             `mapstructure:"batch_size"`
+	Rules            []RetentionRule `mapstructure:"rules"`
+}
+
+// RetentionRule 一条保留规则：Target 中超过 After 的记录执行 Action（anonymize 或 delete）。
+// 可用的 Target 与各自支持的 Action 见 retention 包
+type RetentionRule struct {
+	Name   string        `mapstructure:"name"`
+	Target string        `mapstructure:"target"`
+	Action string        `mapstructure:"action"`
+	After  time.Duration `mapstructure:"after"`
+}
+
+// OverdueConfig 待取包裹逾期提醒。入库超过 RemindAfter / WarnAfter / ReturnAfter 分别发送提醒、警告与退回通知，
+// 为 0 的级别不启用；AutoReturn 为 true 时达到 ReturnAfter 的包裹直接标记为待退回
+type OverdueConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Interval    time.Duration `mapstructure:"interval"`
+	RemindAfter time.Duration `mapstructure:"remind_after"`
+	WarnAfter   time.Duration `mapstructure:"warn_after"`
+	ReturnAfter time.Duration `mapstructure:"return_after"`
+	AutoReturn  bool          `mapstructure:"auto_return"`
+}
+
+// ReturnsConfig 退件流程配置，MaxEvidenceBytes 为交接签名或照片的大小上限
+type ReturnsConfig struct {
+	MaxEvidenceBytes int64 `mapstructure:"max_evidence_bytes"`
+}
+
+// StorageFeeConfig 超期保管费，金额单位为分。入库满 FreeDays 天后每天收取 DailyRate 乘以包裹尺寸对应的系数，
+// 合计不超过 Cap（为 0 时不封顶）；收件人角色在 ExemptRoles 中时免收
+type StorageFeeConfig struct {
+	Enabled         bool               `mapstructure:"enabled"`
+	FreeDays        int                `mapstructure:"free_days"`
+	DailyRate       int64              `mapstructure:"daily_rate"`
+	Cap             int64              `mapstructure:"cap"`
+	SizeMultipliers map[string]float64 `mapstructure:"size_multipliers"`
+	ExemptRoles     []string           `mapstructure:"exempt_roles"`
+}
+
+// Quote 计算保管了 held 时长的包裹应收的费用，未配置系数的尺寸按 1 计算
+func (f StorageFeeConfig) Quote(role, size string, held time.Duration) FeeQuote {
+	q := FeeQuote{Multiplier: 1}
+	if held > 0 {
+		q.HeldDays = int((held + 24*time.Hour - 1) / (24 * time.Hour))
+	}
+	q.ChargeableDays = max(q.HeldDays-f.FreeDays, 0)
+	if m, ok := f.SizeMultipliers[size]; ok {
+		q.Multiplier = m
+	}
+	if slices.Contains(f.ExemptRoles, role) {
+		q.Exempt = true
+		return q
+	}
+
+	q.Amount = int64(math.Round(float64(q.ChargeableDays) * float64(f.DailyRate) * q.Multiplier))
+	if f.Cap > 0 && q.Amount > f.Cap {
+		q.Amount = f.Cap
+	}
+	return q
+}
+
+// OverdueThreshold 一个逾期级别及其滞留时长阈值
+type OverdueThreshold struct {
+	Level string
+	After time.Duration
+}
+
+// Levels 已启用的逾期级别，按阈值从小到大排列
+func (o OverdueConfig) Levels() []OverdueThreshold {
+	var levels []OverdueThreshold
+	for _, l := range []OverdueThreshold{{OverdueRemind, o.RemindAfter}, {OverdueWarn, o.WarnAfter}, {OverdueReturn, o.ReturnAfter}} {
+		if l.After > 0 {
+			levels = append(levels, l)
+		}
+	}
+	return levels
+}
+
+// CheckInRange 当前处于 level 级别的待取包裹的入库时间范围：早于 before，且不早于 notBefore（零值表示不设下限）。
+// 逾期报表、包裹列表筛选与指标都按此实时计算，不依赖扫描任务写入的 overdue_level；level 未启用时 ok 为 false
+func (o OverdueConfig) CheckInRange(level string, now time.Time) (before, notBefore time.Time, ok bool) {
+	levels := o.Levels()
+	for i, l := range levels {
+		if l.Level != level {
+			continue
+		}
+		if i+1 < len(levels) {
+			notBefore = now.Add(-levels[i+1].After)
+		}
+		return now.Add(-l.After), notBefore, true
+	}
+	return time.Time{}, time.Time{}, false
+}
+
+// LevelOf 按滞留时长计算应处的逾期级别，未逾期时返回 OverdueNone
+func (o OverdueConfig) LevelOf(age time.Duration) string {
+	level := OverdueNone
+	for _, l := range o.Levels() {
+		if age >= l.After {
+			level = l.Level
+		}
+	}
+	return level
+}
+
+func setDefaults() {
+	viper.SetDefault("server.addr", ":8088")
+	viper.SetDefault("server.read_timeout", "15s")
+	viper.SetDefault("server.read_header_timeout", "5s")
+	viper.SetDefault("server.write_timeout", "30s")
+	viper.SetDefault("server.idle_timeout", "60s")
+	viper.SetDefault("server.drain_delay", "5s")
+	viper.SetDefault("server.shutdown_timeout", "30s")
+
+	viper.SetDefault("database.sslmode", "disable")
+	viper.SetDefault("database.max_open_conns", 25)
+	viper.SetDefault("database.max_idle_conns", 10)
+	viper.SetDefault("database.conn_max_lifetime", "30m")
+	viper.SetDefault("database.conn_max_idle_time", "5m")
+
+	viper.SetDefault("jwt.expiration_hours", 24)
+	viper.SetDefault("jwt.algorithm", "RS256")
+	viper.SetDefault("jwt.rotation_interval", "720h")
+	viper.SetDefault("jwt.pre_publish", "1h")
+	viper.SetDefault("jwt.overlap", "48h")
+
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.format", "json")
+
+	viper.SetDefault("tracing.enabled", false)
+	viper.SetDefault("tracing.exporter", "otlp")
+	viper.SetDefault("tracing.endpoint", "localhost:4318")
+	viper.SetDefault("tracing.service_name", "packchann")
+	viper.SetDefault("tracing.sample_ratio", 1.0)
+
+	viper.SetDefault("metrics.enabled", false)
+	viper.SetDefault("metrics.path", "/metrics")
+
+	viper.SetDefault("rate_limit.enabled", true)
+	viper.SetDefault("rate_limit.ip_per_minute", 20)
+	viper.SetDefault("rate_limit.ip_burst", 10)
+	viper.SetDefault("rate_limit.account_per_minute", 5)
+	viper.SetDefault("rate_limit.account_burst", 5)
+	viper.SetDefault("rate_limit.max_failures", 5)
+	viper.SetDefault("rate_limit.failure_window", "15m")
+	viper.SetDefault("rate_limit.lockout_duration", "15m")
+
+	viper.SetDefault("password.min_length", 8)
+	viper.SetDefault("password.require_letter", true)
+	viper.SetDefault("password.require_digit", true)
+	viper.SetDefault("password.require_symbol", false)
+	viper.SetDefault("password.reset_code_ttl", "10m")
+	viper.SetDefault("password.reset_max_attempts", 5)
+
+	viper.SetDefault("mfa.issuer", "PackChann")
+	viper.SetDefault("mfa.required_roles", []string{"admin"})
+	viper.SetDefault("mfa.token_ttl", "5m")
+	viper.SetDefault("mfa.recovery_codes", 10)
+
+	viper.SetDefault("sso.frontend_callback", "/sso/callback")
+	viper.SetDefault("sso.ticket_ttl", "1m")
+
+	viper.SetDefault("notify.sender", NotifySenderLog)
+	viper.SetDefault("notify.timeout", "10s")
+
+	viper.SetDefault("privacy.deletion_cooling_off", "168h")
+	viper.SetDefault("privacy.reauth_window", "10m")
+
+	viper.SetDefault("overdue.enabled", true)
+	viper.SetDefault("overdue.interval", "1h")
+	viper.SetDefault("overdue.remind_after", "48h")
+	viper.SetDefault("overdue.warn_after", "120h")
+	viper.SetDefault("overdue.return_after", "168h")
+	viper.SetDefault("overdue.auto_return", false)
+
+	viper.SetDefault("returns.max_evidence_bytes", 5<<20)
+
+	viper.SetDefault("storage_fee.enabled", false)
+	viper.SetDefault("storage_fee.free_days", 3)
+	viper.SetDefault("storage_fee.daily_rate", 50)
+	viper.SetDefault("storage_fee.cap", 1000)
+	viper.SetDefault("storage_fee.size_multipliers", map[string]float64{PackSizeSmall: 1, PackSizeMedium: 1, PackSizeLarge: 2})
+	viper.SetDefault("storage_fee.exempt_roles", []string{"admin"})
+
+	viper.SetDefault("retention.enabled", true)
+	viper.SetDefault("retention.dry_run", true)
+	viper.SetDefault("retention.interval", "24h")
+	viper.SetDefault("retention.archive_dir", "archive")
+	viper.SetDefault("retention.archive_retention", "720h")
+	viper.SetDefault("retention.batch_size", 1000)
+	viper.SetDefault("retention.rules", []map[string]any{
+		{"name": "collected-packs", "target": "packs", "action": "anonymize", "after": "4320h"},
+		{"name": "sessions", "target": "sessions", "action": "delete", "after": "720h"},
+		{"name": "password-resets", "target": "password_resets", "action": "delete", "after": "168h"},
+	})
 }
 
 func LoadConfig() (*Config, error) {
 	viper.SetConfigName("config")
 	viper.SetConfigType("toml")
 	viper.AddConfigPath("config")
+	setDefaults()
+	if err := viper.BindEnv("jwt.key_encryption_key", "PACKCHANN_JWT_KEY_ENCRYPTION_KEY"); err != nil {
+		return nil, err
+	}
 
 	if err := viper.ReadInConfig(); err != nil {
 		return nil, err
@@ -35,6 +498,9 @@ func LoadConfig() (*Config, error) {
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, err
 	}
+	if err := config.API.Validate(); err != nil {
+		return nil, err
+	}
 
 	return &config, nil
 }
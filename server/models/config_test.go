@@ -0,0 +1,131 @@
+package models
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestStorageFeeQuote(t *testing.T) {
+	cfg := StorageFeeConfig{
+		FreeDays:        3,
+		DailyRate:       50,
+		Cap:             1000,
+		SizeMultipliers: map[string]float64{PackSizeSmall: 0.5, PackSizeMedium: 1, PackSizeLarge: 1.5},
+		ExemptRoles:     []string{"admin"},
+	}
+	day := 24 * time.Hour
+
+	cases := []struct {
+		name string
+		role string
+		size string
+		held time.Duration
+		want FeeQuote
+	}{
+		{"刚入库", "user", PackSizeMedium, 0, FeeQuote{Multiplier: 1}},
+		{"不足一天按一天计", "user", PackSizeMedium, time.Minute, FeeQuote{HeldDays: 1, Multiplier: 1}},
+		{"恰好一天", "user", PackSizeMedium, day, FeeQuote{HeldDays: 1, Multiplier: 1}},
+		{"免费期最后一刻", "user", PackSizeMedium, 3 * day, FeeQuote{HeldDays: 3, Multiplier: 1}},
+		{"超出免费期一秒", "user", PackSizeMedium, 3*day + time.Second, FeeQuote{HeldDays: 4, ChargeableDays: 1, Multiplier: 1, Amount: 50}},
+		{"计费五天", "user", PackSizeMedium, 8 * day, FeeQuote{HeldDays: 8, ChargeableDays: 5, Multiplier: 1, Amount: 250}},
+		{"小件系数", "user", PackSizeSmall, 4 * day, FeeQuote{HeldDays: 4, ChargeableDays: 1, Multiplier: 0.5, Amount: 25}},
+		{"系数结果四舍五入", "user", PackSizeSmall, 6 * day, FeeQuote{HeldDays: 6, ChargeableDays: 3, Multiplier: 0.5, Amount: 75}},
+		{"大件系数", "user", PackSizeLarge, 5 * day, FeeQuote{HeldDays: 5, ChargeableDays: 2, Multiplier: 1.5, Amount: 150}},
+		{"未配置的尺寸按 1 计算", "user", "huge", 5 * day, FeeQuote{HeldDays: 5, ChargeableDays: 2, Multiplier: 1, Amount: 100}},
+		{"空尺寸按 1 计算", "user", "", 5 * day, FeeQuote{HeldDays: 5, ChargeableDays: 2, Multiplier: 1, Amount: 100}},
+		{"恰好达到上限", "user", PackSizeMedium, 23 * day, FeeQuote{HeldDays: 23, ChargeableDays: 20, Multiplier: 1, Amount: 1000}},
+		{"超过上限被截断", "user", PackSizeLarge, 30 * day, FeeQuote{HeldDays: 30, ChargeableDays: 27, Multiplier: 1.5, Amount: 1000}},
+		{"免收角色", "admin", PackSizeLarge, 30 * day, FeeQuote{HeldDays: 30, ChargeableDays: 27, Multiplier: 1.5, Exempt: true}},
+		{"未知角色照常收费", "", PackSizeMedium, 4 * day, FeeQuote{HeldDays: 4, ChargeableDays: 1, Multiplier: 1, Amount: 50}},
+		{"负时长（时钟回拨）", "user", PackSizeMedium, -time.Hour, FeeQuote{Multiplier: 1}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := cfg.Quote(tc.role, tc.size, tc.held); got != tc.want {
+				t.Errorf("Quote(%q, %q, %v) = %+v, want %+v", tc.role, tc.size, tc.held, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestStorageFeeQuoteNoCap(t *testing.T) {
+	cfg := StorageFeeConfig{DailyRate: 50}
+	got := cfg.Quote("user", PackSizeMedium, 100*24*time.Hour)
+	if got.Amount != 5000 || got.ChargeableDays != 100 {
+		t.Errorf("Cap=0 should not clamp, got %+v", got)
+	}
+}
+
+func TestStorageFeeQuoteRounding(t *testing.T) {
+	// 1 天 × 33 分 × 1.5 = 49.5 分，四舍五入为 50
+	cfg := StorageFeeConfig{DailyRate: 33, SizeMultipliers: map[string]float64{PackSizeLarge: 1.5}}
+	if got := cfg.Quote("user", PackSizeLarge, time.Hour); got.Amount != 50 {
+		t.Errorf("Amount = %d, want 50", got.Amount)
+	}
+}
+
+func TestNotifyConfigValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		cfg     NotifyConfig
+		wantErr bool
+	}{
+		{"日志", NotifyConfig{Sender: NotifySenderLog}, false},
+		{"webhook", NotifyConfig{Sender: NotifySenderWebhook, WebhookURL: "https://sms.example.edu.cn/send"}, false},
+		{"webhook 缺少地址", NotifyConfig{Sender: NotifySenderWebhook}, true},
+		{"未知渠道", NotifyConfig{Sender: "carrier-pigeon"}, true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := tc.cfg.Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestJWTConfigKEK(t *testing.T) {
+	cases := []struct {
+		name    string
+		kek     string
+		wantErr bool
+	}{
+		{"32 字节", base64.StdEncoding.EncodeToString(make([]byte, 32)), false},
+		{"未设置", "", true},
+		{"不是 base64", "not base64!", true},
+		{"长度不足", base64.StdEncoding.EncodeToString(make([]byte, 16)), true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			kek, err := JWTConfig{KeyEncryptionKey: tc.kek}.KEK()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("KEK() error = %v, wantErr %v", err, tc.wantErr)
+			}
+			if !tc.wantErr && len(kek) != 32 {
+				t.Errorf("len(KEK()) = %d, want 32", len(kek))
+			}
+		})
+	}
+}
+
+func TestAPIConfigValidate(t *testing.T) {
+	cases := []struct {
+		name    string
+		sunset  string
+		wantErr bool
+	}{
+		{"未配置", "", false},
+		{"日期", "2027-03-01", false},
+		{"带时间", "2027-03-01T00:00:00Z", true},
+		{"格式错误", "2027/03/01", true},
+		{"日期不存在", "2027-02-30", true},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := (APIConfig{LegacySunset: tc.sunset}).Validate(); (err != nil) != tc.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tc.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,12 @@
+package models
+
+// ComponentStatus 单个依赖组件的健康状态
+type ComponentStatus struct {
+	Status string `json:"status"`
+}
+
+// HealthStatus 健康检查响应，Status 为 "ok" 或 "fail"
+type HealthStatus struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components,omitempty"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// UserIdentity 外部身份提供方账号与本地用户的绑定关系
+type UserIdentity struct {
+	IdentityId int64     `gorm:"primaryKey;autoIncrement" json:"identity_id"`
+	UserId     int64     `gorm:"not null;index" json:"user_id"`
+	Provider   string    `gorm:"type:varchar(50);not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject    string    `gorm:"type:varchar(255);not null;uniqueIndex:idx_identity_provider_subject" json:"subject"`
+	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SSOExchangeInput 前端用回调 fragment 中的票据换取登录结果
+type SSOExchangeInput struct {
+	Ticket string `json:"ticket" binding:"required"`
+}
+
+// SSOTicket SSO 回调交给前端的一次性票据，只保存哈希，兑换时标记 UsedAt
+type SSOTicket struct {
+	TicketId   int64      `gorm:"primaryKey;autoIncrement" json:"ticket_id"`
+	UserId     int64      `gorm:"not null;index" json:"user_id"`
+	TicketHash string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	ExpiresAt  time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at"`
+	CreatedAt  time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// RecoveryCode 两步验证恢复码，每个只能使用一次，只保存哈希
+type RecoveryCode struct {
+	CodeId    int64      `gorm:"primaryKey;autoIncrement" json:"code_id"`
+	UserId    int64      `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(64);not null;uniqueIndex" json:"-"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// MFACodeInput 需要当前 TOTP 验证码（或恢复码）确认的操作，UserId 来自路径参数
+type MFACodeInput struct {
+	UserId int64  `json:"user_id" binding:"required"`
+	Code   string `json:"code" binding:"required"`
+}
+
+// MFALoginInput 登录第二步，或管理员首次登录时绑定验证器
+type MFALoginInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// MFATokenInput 凭登录第一步签发的 mfa_token 开始绑定验证器
+type MFATokenInput struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
@@ -1,20 +1,123 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 包裹状态
+const (
+	PackStatusPending    = "pending"
+	PackStatusCheckedOut = "checked_out"
+	PackStatusInTransit  = "in_transit"
+	PackStatusShipped    = "shipped"
+	PackStatusArrived    = "arrived"
+	PackStatusCancelled  = "cancelled"
+	// PackStatusReturnPending 收件人无法取件（如账号已删除、逾期未取），等待退回寄件方
+	PackStatusReturnPending = "return_pending"
+	// PackStatusReturned 已随交接单交给快递公司退回
+	PackStatusReturned = "returned"
+)
+
+// 包裹尺寸，用于计算保管费
+const (
+	PackSizeSmall  = "small"
+	PackSizeMedium = "medium"
+	PackSizeLarge  = "large"
+)
+
+// 逾期提醒级别，随滞留时间逐级升高
+const (
+	OverdueNone   = ""
+	OverdueRemind = "remind"
+	OverdueWarn   = "warn"
+	OverdueReturn = "return"
+)
+
+// packTransitions 允许的状态流转，未列出的流转一律拒绝。取件只能通过取件接口（需要结算保管费），
+// 退件相关的状态只能通过退件流程修改
+var packTransitions = map[string][]string{
+	PackStatusInTransit: {PackStatusShipped, PackStatusCancelled},
+	PackStatusShipped:   {PackStatusArrived},
+}
+
+// IsValidPackStatus 判断是否为已知的包裹状态
+func IsValidPackStatus(status string) bool {
+	switch status {
+	case PackStatusPending, PackStatusCheckedOut, PackStatusInTransit, PackStatusShipped, PackStatusArrived, PackStatusCancelled, PackStatusReturnPending, PackStatusReturned:
+		return true
+	}
+	return false
+}
+
+// CanTransition 判断包裹能否从 from 流转到 to
+func CanTransition(from, to string) bool {
+	for _, next := range packTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
 
 type Pack struct {
-	PackId       int64     `gorm:"primaryKey;index:idx_pack_id,type:btree" json:"pack_id"`
-	UserId       int64     `gorm:"not null;index:idx_packs_user_id,type:btree" json:"user_id"`
-	PackStatus   string    `gorm:"type:varchar(20);default:'pending'" json:"pack_status"`
-	PickupCode   string    `gorm:"type:varchar(10);index:idx_pickup_code,type:btree" json:"pickup_code"`
-	CheckInTime  time.Time `gorm:"autoCreateTime" json:"check_in_time"`
-	CheckOutTime time.Time `json:"check_out_time"`
+	PackId         int64     `gorm:"primaryKey;index:idx_pack_id,type:btree" json:"pack_id"`
+	UserId         int64     `gorm:"not null;index:idx_packs_user_id,type:btree" json:"user_id"`
+	PackStatus     string    `gorm:"type:varchar(20);default:'pending';index:idx_packs_status_check_in,priority:1" json:"pack_status"`
+	PickupCode     string    `gorm:"type:varchar(10);index:idx_pickup_code,type:btree" json:"pickup_code"`
+	ShelfCode      int64     `gorm:"index:idx_packs_shelf_code" json:"shelf_code"`
+	Carrier        string    `gorm:"type:varchar(50);index:idx_packs_carrier" json:"carrier"`
+	TrackingNumber string    `gorm:"type:varchar(64)" json:"tracking_number"`
+	Size           string    `gorm:"type:varchar(10);not null;default:'medium'" json:"size"`
+	CheckInTime    time.Time `gorm:"autoCreateTime;index:idx_packs_status_check_in,priority:2" json:"check_in_time"`
+	CheckOutTime   time.Time `json:"check_out_time"`
+
+	// OverdueLevel 逾期扫描已通知到的最高级别，OverdueNotifiedAt 为最近一次通知时间；取件后保留不变
+	OverdueLevel      string     `gorm:"type:varchar(10);not null;default:''" json:"overdue_level"`
+	OverdueNotifiedAt *time.Time `json:"overdue_notified_at"`
+
+	// ReturnManifestId 待退回包裹所在的交接单
+	ReturnManifestId *int64 `gorm:"index" json:"return_manifest_id"`
+
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
 }
 
 type CheckInPak struct {
-	PackId    int64 `json:"pack_id" binding:"required"`
-	UserId    int64 `json:"user_id" binding:"required"`
-	ShelfCode int64 `json:"shelf_code" binding:"required"`
+	PackId         int64  `json:"pack_id" binding:"required"`
+	UserId         int64  `json:"user_id" binding:"required"`
+	ShelfCode      int64  `json:"shelf_code" binding:"required"`
+	Carrier        string `json:"carrier" binding:"max=50"`
+	TrackingNumber string `json:"tracking_number" binding:"max=64"`
+	Size           string `json:"size" binding:"omitempty,oneof=small medium large"` // 默认 medium
+}
+
+// PackListQuery 管理端包裹列表的分页、排序与筛选参数，时间使用 RFC3339 格式
+type PackListQuery struct {
+	PageQuery
+	Status           string    `form:"status"` // 逗号分隔，如 pending,arrived
+	UserId           int64     `form:"user_id"`
+	Carrier          string    `form:"carrier"`
+	ShelfCode        int64     `form:"shelf_code"`
+	PickupCodePrefix string    `form:"pickup_code_prefix"`
+	CheckInFrom      time.Time `form:"check_in_from"`
+	CheckInTo        time.Time `form:"check_in_to"`
+	Deleted          bool      `form:"deleted"` // 只列出已删除的包裹
+	Overdue          string    `form:"overdue"` // 逗号分隔的逾期级别，只列出仍待取的包裹
+}
+
+// OverdueQuery 逾期报表的分页与筛选参数，Level 按当前滞留时长计算
+type OverdueQuery struct {
+	PageQuery
+	Level     string `form:"level" binding:"omitempty,oneof=remind warn return"`
+	ShelfCode int64  `form:"shelf_code"`
+}
+
+// OverduePack 逾期报表中的一行，AgeHours 为已滞留的小时数
+type OverduePack struct {
+	Pack
+	AgeHours int64  `json:"age_hours"`
+	Level    string `json:"level"`
 }
 
 type CheckOutPak struct {
@@ -24,7 +127,7 @@ type CheckOutPak struct {
 
 type UpdatePackStatus struct {
 	PackId     int64  `json:"pack_id" binding:"required"`
-	PackStatus string `gorm:"type:varchar(20);default:'pending'" json:"pack_status"`
+	PackStatus string `json:"pack_status" binding:"required"`
 }
 
 type AdminUpdatePackInput struct {
@@ -0,0 +1,15 @@
+package models
+
+// PageQuery 通用分页与排序参数，Sort 为逗号分隔的字段名，前缀 "-" 表示降序
+type PageQuery struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"page_size" binding:"omitempty,min=1,max=100"`
+	Sort     string `form:"sort"`
+}
+
+// Page 分页结果元数据
+type Page struct {
+	Total    int64 `json:"total"`
+	Page     int   `json:"page"`
+	PageSize int   `json:"page_size"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// PasswordReset 找回密码的一次性验证码，只保存哈希
+type PasswordReset struct {
+	ResetId   int64      `gorm:"primaryKey;autoIncrement" json:"reset_id"`
+	UserId    int64      `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"type:varchar(255);not null" json:"-"`
+	Attempts  int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt time.Time  `gorm:"not null" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ChangePasswordInput 修改密码，UserId 来自路径参数
+type ChangePasswordInput struct {
+	UserId      int64  `json:"user_id" binding:"required"`
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// ForgotPasswordInput 申请找回密码验证码
+type ForgotPasswordInput struct {
+	StudentId string `json:"student_id" binding:"required"`
+}
+
+// ResetPasswordInput 使用验证码重置密码
+type ResetPasswordInput struct {
+	StudentId   string `json:"student_id" binding:"required"`
+	Code        string `json:"code" binding:"required,len=6,numeric"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
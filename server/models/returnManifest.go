@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// 退件交接单状态
+const (
+	ManifestOpen       = "open"
+	ManifestHandedOver = "handed_over"
+)
+
+// 交接凭证类型
+const (
+	EvidenceSignature = "signature"
+	EvidencePhoto     = "photo"
+)
+
+// ReturnManifest 退回快递公司的交接单。待退回的包裹按快递公司加入交接单，快递员取走时登记交接人并上传签名或照片
+type ReturnManifest struct {
+	ManifestId   int64      `gorm:"primaryKey;autoIncrement" json:"manifest_id"`
+	Carrier      string     `gorm:"type:varchar(50);not null;index" json:"carrier"`
+	Status       string     `gorm:"type:varchar(20);not null;default:'open';index" json:"status"`
+	CreatedBy    int64      `gorm:"not null" json:"created_by"`
+	CreatedAt    time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	HandedOverAt *time.Time `json:"handed_over_at"`
+	HandedOverBy int64      `gorm:"not null;default:0" json:"handed_over_by"`
+	CourierName  string     `gorm:"type:varchar(100)" json:"courier_name"`
+	CourierPhone string     `gorm:"type:varchar(20)" json:"courier_phone"`
+
+	// 交接凭证，通过单独的接口下载
+	EvidenceType        string `gorm:"type:varchar(20)" json:"evidence_type"`
+	EvidenceContentType string `gorm:"type:varchar(50)" json:"evidence_content_type"`
+	Evidence            []byte `gorm:"type:bytea" json:"-"`
+}
+
+// CreateManifestInput 创建交接单。PackIds 为空时加入该快递公司全部尚未加入交接单的待退回包裹
+type CreateManifestInput struct {
+	Carrier string  `json:"carrier" binding:"required,max=50"`
+	PackIds []int64 `json:"pack_ids"`
+}
+
+// ManifestListQuery 交接单列表的分页与筛选参数
+type ManifestListQuery struct {
+	PageQuery
+	Status  string `form:"status" binding:"omitempty,oneof=open handed_over"`
+	Carrier string `form:"carrier"`
+}
+
+// HandoverInput 登记交接，与凭证文件 evidence 一起以 multipart/form-data 提交
+type HandoverInput struct {
+	CourierName  string `form:"courier_name" binding:"required,max=100"`
+	CourierPhone string `form:"courier_phone" binding:"max=20"`
+	EvidenceType string `form:"evidence_type" binding:"required,oneof=signature photo"`
+}
@@ -0,0 +1,25 @@
+package models
+
+// SearchQuery 柜台综合搜索参数
+type SearchQuery struct {
+	Q     string `form:"q" binding:"required,min=2,max=64"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=50"`
+}
+
+// UserHit 用户搜索命中，Score 为 pg_trgm 相似度，越大越相关
+type UserHit struct {
+	User
+	Score float64 `json:"score"`
+}
+
+// PackHit 包裹搜索命中
+type PackHit struct {
+	Pack
+	Score float64 `json:"score"`
+}
+
+// SearchResult 按类型分组的搜索结果，组内按相关度排序
+type SearchResult struct {
+	Users []UserHit `json:"users"`
+	Packs []PackHit `json:"packs"`
+}
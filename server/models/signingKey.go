@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// SigningKey JWT 签名密钥。新密钥创建后先在 JWKS 中公布，到 ActivatesAt 才开始签名；
+// 被下一把密钥取代后于 RetiresAt 停止签名，到 ExpiresAt 前仍用于验证已签发的 Token。
+// 私钥用 jwt.key_encryption_key 加密保存，升级前写入的明文私钥在下一次轮换检查时被加密
+type SigningKey struct {
+	KeyId       string     `gorm:"type:varchar(64);primaryKey" json:"kid"`
+	Algorithm   string     `gorm:"type:varchar(16);not null" json:"alg"`
+	PrivateKey  []byte     `gorm:"not null" json:"-"` // Encrypted 时为 AES-256-GCM 加密的 PKCS#8 DER（nonce || 密文）
+	Encrypted   bool       `gorm:"not null;default:false" json:"-"`
+	CreatedAt   time.Time  `gorm:"autoCreateTime" json:"created_at"`
+	ActivatesAt time.Time  `gorm:"not null" json:"activates_at"`
+	RetiresAt   *time.Time `json:"retires_at"`
+	ExpiresAt   *time.Time `gorm:"index" json:"expires_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import "time"
+
+// 保管费流水类型
+const (
+	FeeCharge = "charge"
+	FeeWaiver = "waiver"
+)
+
+// 系统生成的收费流水备注
+const (
+	FeeNoteFreePeriod = "free_period"
+	FeeNoteExemptRole = "exempt_role"
+	FeeNoteWaived     = "waived"
+)
+
+// StorageFee 包裹保管费流水，金额单位为分。取件时记一笔收费（免费期内或免收时金额为 0），减免记一笔负数金额，
+// 同一包裹全部流水之和为待收金额。取件前减免的包裹在取件时不再收费
+type StorageFee struct {
+	EntryId   int64     `gorm:"primaryKey;autoIncrement" json:"entry_id"`
+	PackId    int64     `gorm:"not null;index" json:"pack_id"`
+	UserId    int64     `gorm:"not null;index" json:"user_id"`
+	Kind      string    `gorm:"type:varchar(10);not null" json:"kind"`
+	Amount    int64     `gorm:"not null" json:"amount"`
+	Days      int       `gorm:"not null;default:0" json:"days"` // 计费天数
+	Note      string    `gorm:"type:varchar(200)" json:"note"`  // 减免原因，或系统生成的备注
+	CreatedBy int64     `gorm:"not null;default:0" json:"created_by"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// FeeQuote 按当前策略计算的保管费
+type FeeQuote struct {
+	HeldDays       int     `json:"held_days"`       // 已保管天数，不足一天按一天计
+	ChargeableDays int     `json:"chargeable_days"` // 扣除免费天数后的计费天数
+	Multiplier     float64 `json:"multiplier"`
+	Amount         int64   `json:"amount"`
+	Exempt         bool    `json:"exempt"`
+}
+
+// WaiveFeeInput 减免保管费，PackId 来自路径参数
+type WaiveFeeInput struct {
+	PackId int64  `json:"pack_id" binding:"required"`
+	Reason string `json:"reason" binding:"required,max=200"`
+}
@@ -1,10 +1,25 @@
 package models
 
 type SystemStatus struct {
-	Cpu    string   `json:"cpu"`
-	Memory string   `json:"memory"`
-	Swap   string   `json:"swap"`
-	Disk   []string `json:"disk"`
+	Cpu      string         `json:"cpu"`
+	Memory   string         `json:"memory"`
+	Swap     string         `json:"swap"`
+	Disk     []string       `json:"disk"`
+	Database []DBPoolStatus `json:"database"`
+}
+
+// DBPoolStatus 数据库连接池统计，对应 sql.DBStats
+type DBPoolStatus struct {
+	Name              string `json:"name"`
+	MaxOpenConns      int    `json:"max_open_conns"`
+	OpenConns         int    `json:"open_conns"`
+	InUse             int    `json:"in_use"`
+	Idle              int    `json:"idle"`
+	WaitCount         int64  `json:"wait_count"`
+	WaitDuration      string `json:"wait_duration"`
+	MaxIdleClosed     int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
 }
 
 func (s *SystemStatus) NewSystemStatus(cpu, memory, swap string, disk []string) *SystemStatus {
@@ -2,6 +2,8 @@ package models
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type User struct {
@@ -12,7 +14,88 @@ type User struct {
 	Phone        string    `gorm:"type:varchar(20);unique;not null" json:"phone"`
 	Address      string    `gorm:"type:varchar(255)" json:"address"`
 	Role         string    `gorm:"type:varchar(20);default:'user';not null" json:"role"`
+	Language     string    `gorm:"type:varchar(10)" json:"language"` // 语言偏好 (zh-CN / en)，为空时按 Accept-Language
 	RegisterTime time.Time `gorm:"autoCreateTime;not null" json:"register_time"`
+
+	// 两步验证：TOTPSecret 在绑定开始时写入，验证通过后 TOTPEnabled 才置为 true；TOTPLastStep 防止验证码重放
+	TOTPSecret   string `gorm:"type:varchar(64)" json:"-"`
+	TOTPEnabled  bool   `gorm:"not null;default:false" json:"totp_enabled"`
+	TOTPLastStep int64  `gorm:"not null;default:0" json:"-"`
+
+	// DeletedAt 软删除时间，学号与手机号的唯一约束仍然有效，管理员可以恢复
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at"`
+
+	// 自助注销：DeletionDueAt 为冷静期结束时间，到期后清除个人信息并写入 AnonymizedAt，匿名化后不能恢复
+	DeletionDueAt *time.Time `gorm:"index" json:"deletion_due_at"`
+	AnonymizedAt  *time.Time `json:"anonymized_at"`
+
+	// HasPassword 是否设置了本地密码，仅通过统一身份认证创建的账号没有密码
+	HasPassword bool `gorm:"-" json:"has_password"`
+}
+
+// AfterFind 根据密码哈希填充 HasPassword
+func (u *User) AfterFind(tx *gorm.DB) error {
+	u.HasPassword = u.PasswordHash != ""
+	return nil
+}
+
+// DeletionRequestInput 用户申请注销自己的账号，UserId 来自路径参数。有本地密码时需要再次输入密码；
+// 没有密码但开启了两步验证时需要 Code（TOTP 验证码或恢复码）
+type DeletionRequestInput struct {
+	UserId   int64  `json:"user_id" binding:"required"`
+	Password string `json:"password"`
+	Code     string `json:"code"`
+}
+
+// UpdateUserInput 修改用户资料，只包含用户可以自行修改的字段，未提供的字段保持不变；UserId 来自路径参数。
+// 角色通过 UpdateRoleInput 由管理员单独修改
+type UpdateUserInput struct {
+	UserId   int64   `json:"user_id" binding:"required"`
+	UserName *string `json:"user_name" binding:"omitempty,min=1,max=100"`
+	Address  *string `json:"address" binding:"omitempty,max=255"`
+	Phone    *string `json:"phone" binding:"omitempty,min=1,max=20"`
+	Language *string `json:"language"`
+}
+
+// UpdateRoleInput 管理员修改用户角色，UserId 来自路径参数
+type UpdateRoleInput struct {
+	UserId int64  `json:"user_id" binding:"required"`
+	Role   string `json:"role" binding:"required,oneof=user admin"`
+}
+
+// ExportQuery 个人数据导出格式，默认 zip
+type ExportQuery struct {
+	Format string `form:"format" binding:"omitempty,oneof=zip json"`
+}
+
+// UserListQuery 管理端用户列表的分页、排序与筛选参数，Deleted 为 true 时只列出已删除的用户；
+// Q 按姓名、学号或手机号模糊匹配
+type UserListQuery struct {
+	PageQuery
+	Role    string `form:"role"`
+	Deleted bool   `form:"deleted"`
+	Q       string `form:"q" binding:"max=64"`
+}
+
+// 删除用户时对未取件包裹的处理方式
+const (
+	ParcelsBlock    = "block"
+	ParcelsTransfer = "transfer"
+	ParcelsReturn   = "return"
+)
+
+// DeleteUserQuery 删除用户的参数。存在未取件包裹时，Parcels 为 block（默认）拒绝删除，
+// transfer 转给 TransferTo 用户，return 标记为待退回寄件方
+type DeleteUserQuery struct {
+	Parcels    string `form:"parcels" binding:"omitempty,oneof=block transfer return"`
+	TransferTo int64  `form:"transfer_to" binding:"required_if=Parcels transfer"`
+}
+
+// UserDeletionCheck 删除用户前的检查结果
+type UserDeletionCheck struct {
+	User             User   `json:"user"`
+	UncollectedPacks []Pack `json:"uncollected_packs"`
+	ActiveSessions   int64  `json:"active_sessions"`
 }
 
 type UserLogin struct {
@@ -0,0 +1,115 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+
+	"github.com/yurin-kami/PackChann/i18n"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/tracing"
+)
+
+// 通知码，对应消息目录中的 "<code>.title" 与 "<code>.body" 模板
+const (
+	NoticePackCheckedIn     = "PACK_CHECKED_IN"
+	NoticePasswordResetCode = "PASSWORD_RESET_CODE"
+	NoticePackOverdueRemind = "PACK_OVERDUE_REMIND"
+	NoticePackOverdueWarn   = "PACK_OVERDUE_WARN"
+	NoticePackOverdueReturn = "PACK_OVERDUE_RETURN"
+	NoticePackReturnPending = "PACK_RETURN_PENDING"
+	NoticePackReturned      = "PACK_RETURNED"
+)
+
+// Message 一条待发送的通知，正文在发送时按收件人语言渲染。Secrets 为不能写入日志的参数名（如验证码）
+type Message struct {
+	Code    string
+	Params  map[string]any
+	Secrets []string
+}
+
+// redactedParam 通过不安全的渠道发送时替换 Secrets 中的参数
+const redactedParam = "******"
+
+// Sender 实际投递通知的渠道（短信、邮件、站内信等）
+type Sender interface {
+	Send(ctx context.Context, user models.User, title, body string) error
+}
+
+// insecureSender 通知写到运维可见的位置而没有送达用户，不能用来发送验证码
+type insecureSender interface {
+	insecure()
+}
+
+// Notifier 按用户语言偏好渲染通知模板并交给 Sender 投递
+type Notifier struct {
+	sender Sender
+}
+
+func NewNotifier(sender Sender) *Notifier {
+	return &Notifier{sender: sender}
+}
+
+// NewSender 按配置创建通知渠道
+func NewSender(cfg models.NotifyConfig, logger *slog.Logger) (Sender, error) {
+	switch cfg.Sender {
+	case models.NotifySenderLog:
+		return LogSender{Logger: logger}, nil
+	case models.NotifySenderWebhook:
+		return &WebhookSender{URL: cfg.WebhookURL, Token: cfg.WebhookToken, Client: &http.Client{Timeout: cfg.Timeout}}, nil
+	default:
+		return nil, fmt.Errorf("notify: unknown sender %q", cfg.Sender)
+	}
+}
+
+// Secure 通知是否真正送达用户。为 false 时 Secrets 中的参数会被替换，不能依赖通知发送验证码
+func (n *Notifier) Secure() bool {
+	_, insecure := n.sender.(insecureSender)
+	return !insecure
+}
+
+// Notify 渲染并发送通知，整个过程记录在一个 span 中
+func (n *Notifier) Notify(ctx context.Context, user models.User, msg Message) (err error) {
+	ctx, span := tracing.Start(ctx, "notify."+msg.Code)
+	defer func() { tracing.End(span, err) }()
+
+	lang := i18n.Normalize(user.Language)
+	if lang == "" {
+		lang = i18n.DefaultLang
+	}
+	if len(msg.Secrets) > 0 && !n.Secure() {
+		params := maps.Clone(msg.Params)
+		if params == nil {
+			params = map[string]any{}
+		}
+		for _, name := range msg.Secrets {
+			params[name] = redactedParam
+		}
+		msg.Params = params
+	}
+
+	title, err := i18n.Render(lang, msg.Code+".title", msg.Params)
+	if err != nil {
+		return fmt.Errorf("render %s title: %w", msg.Code, err)
+	}
+	body, err := i18n.Render(lang, msg.Code+".body", msg.Params)
+	if err != nil {
+		return fmt.Errorf("render %s body: %w", msg.Code, err)
+	}
+
+	return n.sender.Send(ctx, user, title, body)
+}
+
+// LogSender 只把通知写入日志，用于本地开发。日志对运维可见，Notifier 不会把验证码交给它
+type LogSender struct {
+	Logger *slog.Logger
+}
+
+func (LogSender) insecure() {}
+
+func (s LogSender) Send(ctx context.Context, user models.User, title, body string) error {
+	s.Logger.InfoContext(ctx, "notification", "user_id", user.UserId, "title", title, "body", body)
+	return nil
+}
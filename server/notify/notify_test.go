@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/yurin-kami/PackChann/models"
+)
+
+type captureSender struct {
+	body string
+}
+
+func (s *captureSender) Send(_ context.Context, _ models.User, _, body string) error {
+	s.body = body
+	return nil
+}
+
+// insecureCapture 与 LogSender 一样不能送达用户
+type insecureCapture struct {
+	captureSender
+}
+
+func (insecureCapture) insecure() {}
+
+func resetMessage() Message {
+	return Message{Code: NoticePasswordResetCode, Params: map[string]any{"Code": "123456", "Minutes": 15}, Secrets: []string{"Code"}}
+}
+
+func TestNotifySecureSenderGetsSecrets(t *testing.T) {
+	sender := &captureSender{}
+	n := NewNotifier(sender)
+	if !n.Secure() {
+		t.Fatal("Secure() = false for a delivering sender")
+	}
+	if err := n.Notify(context.Background(), models.User{Language: "en"}, resetMessage()); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(sender.body, "123456") {
+		t.Errorf("body = %q, want the code", sender.body)
+	}
+}
+
+func TestNotifyInsecureSenderRedactsSecrets(t *testing.T) {
+	sender := &insecureCapture{}
+	n := NewNotifier(sender)
+	if n.Secure() {
+		t.Fatal("Secure() = true for an insecure sender")
+	}
+	msg := resetMessage()
+	if err := n.Notify(context.Background(), models.User{Language: "en"}, msg); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sender.body, "123456") || !strings.Contains(sender.body, redactedParam) {
+		t.Errorf("body = %q, want the code redacted", sender.body)
+	}
+	if msg.Params["Code"] != "123456" {
+		t.Error("Notify modified the caller's params")
+	}
+}
+
+func TestLogSenderIsInsecure(t *testing.T) {
+	if NewNotifier(LogSender{Logger: slog.Default()}).Secure() {
+		t.Error("LogSender must not be treated as delivering to the user")
+	}
+}
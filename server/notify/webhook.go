@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/yurin-kami/PackChann/models"
+)
+
+// WebhookSender 把渲染好的通知 POST 给短信/邮件网关，非 2xx 响应视为发送失败
+type WebhookSender struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+type webhookPayload struct {
+	UserId   int64  `json:"user_id"`
+	Phone    string `json:"phone"`
+	Language string `json:"language"`
+	Title    string `json:"title"`
+	Body     string `json:"body"`
+}
+
+func (s *WebhookSender) Send(ctx context.Context, user models.User, title, body string) error {
+	data, err := json.Marshal(webhookPayload{UserId: user.UserId, Phone: user.Phone, Language: user.Language, Title: title, Body: body})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("notify webhook: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("notify webhook: unexpected status %s", resp.Status)
+	}
+	return nil
+}
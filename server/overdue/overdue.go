@@ -0,0 +1,114 @@
+// Package overdue 扫描长时间未取的包裹，按滞留时长逐级升高提醒级别并通知收件人
+package overdue
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const batchSize = 500
+
+var notices = map[string]string{
+	models.OverdueRemind: notify.NoticePackOverdueRemind,
+	models.OverdueWarn:   notify.NoticePackOverdueWarn,
+	models.OverdueReturn: notify.NoticePackOverdueReturn,
+}
+
+// Scan 执行一次扫描，返回每个级别新升级的包裹数。从最高级别开始处理，滞留很久的包裹直接升到对应级别，只收到一条通知。
+// 级别在事务中更新，通知在提交后发送，发送失败只记录日志，不会重复通知
+func Scan(ctx context.Context, db *gorm.DB, notifier *notify.Notifier, cfg models.OverdueConfig, now time.Time) (map[string]int, error) {
+	counts := map[string]int{}
+	levels := cfg.Levels()
+	for i := len(levels) - 1; i >= 0; i-- {
+		lower := []string{models.OverdueNone}
+		for _, l := range levels[:i] {
+			lower = append(lower, l.Level)
+		}
+		autoReturn := cfg.AutoReturn && levels[i].Level == models.OverdueReturn
+
+		for {
+			packs, err := escalate(ctx, db, levels[i], lower, autoReturn, now)
+			if err != nil {
+				return counts, err
+			}
+			counts[levels[i].Level] += len(packs)
+			notifyRecipients(ctx, db, notifier, packs, levels[i].Level, now)
+			if len(packs) < batchSize {
+				break
+			}
+		}
+	}
+	return counts, nil
+}
+
+// escalate 把一批达到阈值且级别更低的待取包裹升到 level
+func escalate(ctx context.Context, db *gorm.DB, level models.OverdueThreshold, lower []string, autoReturn bool, now time.Time) ([]models.Pack, error) {
+	var packs []models.Pack
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("pack_status = ? AND check_in_time < ? AND overdue_level IN ?", models.PackStatusPending, now.Add(-level.After), lower).
+			Order("check_in_time").Limit(batchSize).
+			Find(&packs).Error
+		if err != nil || len(packs) == 0 {
+			return err
+		}
+
+		for i := range packs {
+			before := packs[i]
+			updates := map[string]any{"overdue_level": level.Level, "overdue_notified_at": now}
+			if autoReturn {
+				updates["pack_status"] = models.PackStatusReturnPending
+			}
+			if err := tx.Model(&packs[i]).Updates(updates).Error; err != nil {
+				return err
+			}
+			if autoReturn {
+				if err := audit.RecordSystem(tx, audit.Entry{Action: "pack.return", TargetType: "pack", TargetId: packs[i].PackId, Before: before, After: packs[i]}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return packs, err
+}
+
+func notifyRecipients(ctx context.Context, db *gorm.DB, notifier *notify.Notifier, packs []models.Pack, level string, now time.Time) {
+	if len(packs) == 0 {
+		return
+	}
+	ids := make([]int64, len(packs))
+	for i, p := range packs {
+		ids[i] = p.UserId
+	}
+	var users []models.User
+	if err := db.WithContext(ctx).Where("user_id IN ?", ids).Find(&users).Error; err != nil {
+		slog.WarnContext(ctx, "overdue: failed to load recipients", "error", err)
+		return
+	}
+	byId := make(map[int64]models.User, len(users))
+	for _, u := range users {
+		byId[u.UserId] = u
+	}
+
+	for _, p := range packs {
+		user, ok := byId[p.UserId]
+		if !ok {
+			continue
+		}
+		err := notifier.Notify(ctx, user, notify.Message{
+			Code:   notices[level],
+			Params: map[string]any{"PackId": p.PackId, "PickupCode": p.PickupCode, "Days": int(now.Sub(p.CheckInTime).Hours() / 24)},
+		})
+		if err != nil {
+			slog.WarnContext(ctx, "overdue: failed to notify recipient", "pack_id", p.PackId, "level", level, "error", err)
+		}
+	}
+}
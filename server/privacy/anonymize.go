@@ -0,0 +1,108 @@
+package privacy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AnonymizedName 匿名化后显示的用户名
+const AnonymizedName = "已注销用户"
+
+// AnonymizeDue 匿名化注销冷静期已结束的账号，返回处理的数量。每个账号一个事务，出错时停止，剩余的留到下次执行
+func AnonymizeDue(ctx context.Context, db *gorm.DB, now time.Time) (int, error) {
+	var ids []int64
+	err := db.WithContext(ctx).Unscoped().Model(&models.User{}).
+		Where("deletion_due_at <= ? AND anonymized_at IS NULL", now).
+		Pluck("user_id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, id := range ids {
+		ok, err := anonymize(ctx, db, id, now)
+		if err != nil {
+			return done, fmt.Errorf("anonymize user %d: %w", id, err)
+		}
+		if ok {
+			done++
+		}
+	}
+	return done, nil
+}
+
+// anonymize 清除用户的个人信息，保留用户 ID、角色与注册时间，包裹记录不变，统计数据不受影响。
+// 学号与手机号替换为由用户 ID 生成的占位值，原学号和手机号可以重新注册
+func anonymize(ctx context.Context, db *gorm.DB, userId int64, now time.Time) (bool, error) {
+	done := false
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 加锁后重新检查，用户可能刚刚撤销了申请
+		var user models.User
+		err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND deletion_due_at <= ? AND anonymized_at IS NULL", userId, now).
+			First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		// 冷静期内新到站的包裹无人领取，标记为待退回
+		var packs []models.Pack
+		err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND pack_status = ?", userId, models.PackStatusPending).
+			Find(&packs).Error
+		if err != nil {
+			return err
+		}
+		for _, pack := range packs {
+			before := pack
+			if err := tx.Model(&pack).Update("pack_status", models.PackStatusReturnPending).Error; err != nil {
+				return err
+			}
+			if err := audit.RecordSystem(tx, audit.Entry{Action: "pack.return", TargetType: "pack", TargetId: pack.PackId, Before: before, After: pack}); err != nil {
+				return err
+			}
+		}
+
+		alias := "anon-" + strconv.FormatInt(userId, 36)
+		updates := map[string]any{
+			"user_name":       AnonymizedName,
+			"student_id":      alias,
+			"phone":           alias,
+			"address":         "",
+			"password_hash":   "",
+			"language":        "",
+			"totp_secret":     "",
+			"totp_enabled":    false,
+			"totp_last_step":  0,
+			"deletion_due_at": nil,
+			"anonymized_at":   now,
+		}
+		if !user.DeletedAt.Valid {
+			updates["deleted_at"] = now
+		}
+		if err := tx.Unscoped().Model(&user).Updates(updates).Error; err != nil {
+			return err
+		}
+		for _, model := range []any{&models.UserToken{}, &models.UserIdentity{}, &models.SSOTicket{}, &models.RecoveryCode{}, &models.PasswordReset{}} {
+			if err := tx.Where("user_id = ?", userId).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		// 不记录变更前后的值，否则个人信息会留在只能追加的审计表中
+		done = true
+		return audit.RecordSystem(tx, audit.Entry{Action: "user.anonymize", TargetType: "user", TargetId: userId})
+	})
+	return done, err
+}
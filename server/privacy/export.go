@@ -0,0 +1,114 @@
+// Package privacy 个人信息保护：导出用户的全部个人数据，以及注销冷静期结束后的匿名化
+package privacy
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"time"
+
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+)
+
+// mailStatuses 寄件流程中的包裹状态，其余状态为到站待取的包裹
+var mailStatuses = []string{models.PackStatusInTransit, models.PackStatusShipped, models.PackStatusArrived, models.PackStatusCancelled}
+
+// Session 登录会话，不包含 Token 本身，导出文件泄露时不能被用来登录
+type Session struct {
+	TokenId   int64     `json:"token_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Archive 一个用户的全部个人数据。系统不记录通知的阅读情况，因此没有对应部分
+type Archive struct {
+	ExportedAt time.Time             `json:"exported_at"`
+	Profile    models.User           `json:"profile"`
+	Identities []models.UserIdentity `json:"identities"`
+	Parcels    []models.Pack         `json:"parcels"`
+	MailOuts   []models.Pack         `json:"mail_outs"`
+	Fees       []models.StorageFee   `json:"fees"`
+	Sessions   []Session             `json:"sessions"`
+	Activity   []models.AuditLog     `json:"activity"` // 本人发起的敏感操作
+}
+
+// Export 在同一个只读快照中读取用户的全部数据，保证各部分互相一致
+func Export(ctx context.Context, db *gorm.DB, user models.User) (*Archive, error) {
+	a := &Archive{
+		ExportedAt: time.Now(),
+		Profile:    user,
+		Identities: []models.UserIdentity{},
+		Parcels:    []models.Pack{},
+		MailOuts:   []models.Pack{},
+		Fees:       []models.StorageFee{},
+		Sessions:   []Session{},
+		Activity:   []models.AuditLog{},
+	}
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", user.UserId).Order("created_at").Find(&a.Identities).Error; err != nil {
+			return err
+		}
+		// 管理员软删除的包裹仍属于用户的个人数据，一并导出
+		if err := tx.Unscoped().Where("user_id = ? AND pack_status NOT IN ?", user.UserId, mailStatuses).Order("check_in_time").Find(&a.Parcels).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ? AND pack_status IN ?", user.UserId, mailStatuses).Order("check_in_time").Find(&a.MailOuts).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", user.UserId).Order("entry_id").Find(&a.Fees).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.UserToken{}).Where("user_id = ?", user.UserId).Order("created_at").Find(&a.Sessions).Error; err != nil {
+			return err
+		}
+		return tx.Where("actor_type = ? AND actor_id = ?", models.ActorUser, user.UserId).Order("created_at").Find(&a.Activity).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// WriteZip 每个部分写成一个 JSON 文件，另附 manifest.json 说明导出时间与包含的文件
+func (a *Archive) WriteZip(w io.Writer) error {
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"profile.json", a.Profile},
+		{"identities.json", a.Identities},
+		{"parcels.json", a.Parcels},
+		{"mail_outs.json", a.MailOuts},
+		{"fees.json", a.Fees},
+		{"sessions.json", a.Sessions},
+		{"activity.json", a.Activity},
+	}
+	names := make([]string, len(files))
+	for i, f := range files {
+		names[i] = f.name
+	}
+	manifest := map[string]any{"user_id": a.Profile.UserId, "exported_at": a.ExportedAt, "files": names}
+
+	zw := zip.NewWriter(w)
+	write := func(name string, v any) error {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: a.ExportedAt})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	if err := write("manifest.json", manifest); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := write(f.name, f.v); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,140 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+	rate   Rate
+}
+
+type entry struct {
+	count   int64
+	expires time.Time
+}
+
+// MemoryStore 进程内的 Store 实现，需定期调用 Sweep 清理过期数据
+type MemoryStore struct {
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	entries map[string]*entry
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+		entries: make(map[string]*entry),
+	}
+}
+
+// refill 按距上次取令牌的时间补充令牌，不超过桶容量
+func (b *bucket) refill(now time.Time) {
+	elapsed := now.Sub(b.last).Minutes()
+	b.tokens = math.Min(float64(b.rate.Burst), b.tokens+elapsed*b.rate.PerMinute)
+	b.last = now
+}
+
+func (s *MemoryStore) Take(_ context.Context, key string, rate Rate) (bool, time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(rate.Burst), last: now}
+		s.buckets[key] = b
+	}
+	b.rate = rate
+	b.refill(now)
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0, nil
+	}
+	if rate.PerMinute <= 0 {
+		return false, time.Minute, nil
+	}
+	wait := time.Duration((1 - b.tokens) / rate.PerMinute * float64(time.Minute))
+	return false, wait, nil
+}
+
+// live 返回未过期的条目，过期的顺手删除；调用方需持有锁
+func (s *MemoryStore) live(key string, now time.Time) *entry {
+	e, ok := s.entries[key]
+	if !ok {
+		return nil
+	}
+	if !now.Before(e.expires) {
+		delete(s.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (s *MemoryStore) Incr(_ context.Context, key string, ttl time.Duration) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e := s.live(key, now)
+	if e == nil {
+		e = &entry{expires: now.Add(ttl)}
+		s.entries[key] = e
+	}
+	e.count++
+	return e.count, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.entries[key] = &entry{count: 1, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+func (s *MemoryStore) TTL(_ context.Context, key string) (time.Duration, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if e := s.live(key, now); e != nil {
+		return e.expires.Sub(now), nil
+	}
+	return 0, nil
+}
+
+func (s *MemoryStore) Del(_ context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.entries, key)
+		delete(s.buckets, key)
+	}
+	return nil
+}
+
+// Sweep 删除已过期的计数和已回满的令牌桶（回满的桶与不存在等价）
+func (s *MemoryStore) Sweep() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for key, e := range s.entries {
+		if !now.Before(e.expires) {
+			delete(s.entries, key)
+		}
+	}
+	for key, b := range s.buckets {
+		b.refill(now)
+		if b.tokens >= float64(b.rate.Burst) {
+			delete(s.buckets, key)
+		}
+	}
+}
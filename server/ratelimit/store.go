@@ -0,0 +1,25 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+// Rate 令牌桶参数：每分钟补充 PerMinute 个令牌，桶容量为 Burst
+type Rate struct {
+	PerMinute float64
+	Burst     int
+}
+
+// Store 限流状态存储。MemoryStore 只适用于单实例部署，多实例部署时实现一个基于 Redis 的 Store 替换即可
+type Store interface {
+	// Take 从 key 对应的令牌桶取一个令牌，取不到时返回还需等待的时长
+	Take(ctx context.Context, key string, rate Rate) (bool, time.Duration, error)
+	// Incr 计数加一并返回新值，计数从第一次递增起 ttl 后过期
+	Incr(ctx context.Context, key string, ttl time.Duration) (int64, error)
+	// Set 写入一个 ttl 后过期的标记
+	Set(ctx context.Context, key string, ttl time.Duration) error
+	// TTL 返回 key 的剩余存活时间，不存在或已过期时返回 0
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	Del(ctx context.Context, keys ...string) error
+}
@@ -0,0 +1,255 @@
+// Package retention 按保留规则匿名化或删除过期数据。每批记录先写入 JSONL.gz 归档并落盘，再在同一事务中修改，
+// 多个实例同时执行时用 SKIP LOCKED 分摊批次，不会重复处理。归档按 archive_retention 定期删除
+package retention
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/yurin-kami/PackChann/audit"
+	"github.com/yurin-kami/PackChann/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	ActionAnonymize = "anonymize"
+	ActionDelete    = "delete"
+)
+
+// archivePattern 归档文件名，Prune 只删除符合该格式的文件
+const archivePattern = "*.jsonl.gz"
+
+// target 一类可清理的数据。scope 选出早于 cutoff 且尚未处理的记录，anonymize 为匿名化时写入的值，为空表示不支持匿名化
+type target struct {
+	table     string
+	key       string
+	scope     func(tx *gorm.DB, cutoff time.Time) *gorm.DB
+	anonymize map[string]any
+}
+
+// finishedPackStatuses 流程已结束的包裹：已取件、已退回、寄件已送达或已取消
+var finishedPackStatuses = []string{models.PackStatusCheckedOut, models.PackStatusReturned, models.PackStatusArrived, models.PackStatusCancelled}
+
+var targets = map[string]target{
+	// 已结束的包裹解除与用户的关联并清除取件码、快递单号，保留状态、快递公司、货架与时间用于统计
+	"packs": {
+		table: "packs",
+		key:   "pack_id",
+		scope: func(tx *gorm.DB, cutoff time.Time) *gorm.DB {
+			return tx.Where("pack_status IN ? AND GREATEST(check_in_time, check_out_time) < ? AND (user_id <> 0 OR pickup_code <> '' OR tracking_number <> '')",
+				finishedPackStatuses, cutoff)
+		},
+		anonymize: map[string]any{"user_id": 0, "pickup_code": "", "tracking_number": ""},
+	},
+	// 登录会话按过期时间计算
+	"sessions": {
+		table: "user_tokens",
+		key:   "token_id",
+		scope: func(tx *gorm.DB, cutoff time.Time) *gorm.DB {
+			return tx.Where("expires_at < ?", cutoff)
+		},
+	},
+	"password_resets": {
+		table: "password_resets",
+		key:   "reset_id",
+		scope: func(tx *gorm.DB, cutoff time.Time) *gorm.DB {
+			return tx.Where("created_at < ?", cutoff)
+		},
+	},
+}
+
+// Result 一条规则的执行结果。DryRun 时 Matched 为将被处理的记录数，Processed 为 0
+type Result struct {
+	Rule      string    `json:"rule"`
+	Target    string    `json:"target"`
+	Action    string    `json:"action"`
+	After     string    `json:"after"`
+	Cutoff    time.Time `json:"cutoff"`
+	Matched   int64     `json:"matched"`
+	Processed int64     `json:"processed"`
+	Archive   string    `json:"archive,omitempty"`
+}
+
+// Validate 检查规则引用的 Target 与 Action 是否存在，启动时调用
+func Validate(rules []models.RetentionRule) error {
+	names := map[string]bool{}
+	for _, r := range rules {
+		t, ok := targets[r.Target]
+		if !ok {
+			return fmt.Errorf("retention rule %q: unknown target %q", r.Name, r.Target)
+		}
+		switch r.Action {
+		case ActionDelete:
+		case ActionAnonymize:
+			if t.anonymize == nil {
+				return fmt.Errorf("retention rule %q: target %q cannot be anonymized", r.Name, r.Target)
+			}
+		default:
+			return fmt.Errorf("retention rule %q: unknown action %q", r.Name, r.Action)
+		}
+		if r.Name == "" || names[r.Name] {
+			return fmt.Errorf("retention rule %q: name must be unique and non-empty", r.Name)
+		}
+		if r.After <= 0 {
+			return fmt.Errorf("retention rule %q: after must be positive", r.Name)
+		}
+		names[r.Name] = true
+	}
+	return nil
+}
+
+// Prune 删除 dir 中修改时间早于 now - maxAge 的归档文件，返回被删除的文件名。maxAge 为 0 时不删除
+func Prune(dir string, maxAge time.Duration, now time.Time) ([]string, error) {
+	if maxAge <= 0 {
+		return nil, nil
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, archivePattern))
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return removed, err
+		}
+		if !info.Mode().IsRegular() || !info.ModTime().Before(now.Add(-maxAge)) {
+			continue
+		}
+		if err := os.Remove(path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, filepath.Base(path))
+	}
+	return removed, nil
+}
+
+// Run 依次执行全部规则。dryRun 为 true 时只统计，不写归档也不修改数据
+func Run(ctx context.Context, db *gorm.DB, cfg models.RetentionConfig, now time.Time, dryRun bool) ([]Result, error) {
+	results := make([]Result, 0, len(cfg.Rules))
+	for _, rule := range cfg.Rules {
+		t, ok := targets[rule.Target]
+		if !ok {
+			return results, fmt.Errorf("retention rule %q: unknown target %q", rule.Name, rule.Target)
+		}
+		res := Result{Rule: rule.Name, Target: rule.Target, Action: rule.Action, After: rule.After.String(), Cutoff: now.Add(-rule.After)}
+
+		err := t.scope(db.WithContext(ctx).Table(t.table), res.Cutoff).Count(&res.Matched).Error
+		if err == nil && !dryRun && res.Matched > 0 {
+			err = apply(ctx, db, cfg, t, &res)
+		}
+		results = append(results, res)
+		if err != nil {
+			return results, fmt.Errorf("retention rule %q: %w", rule.Name, err)
+		}
+	}
+	return results, nil
+}
+
+// apply 分批处理一条规则，每批先追加到归档文件并同步到磁盘，再修改数据库。
+// 修改失败时归档中会多出这一批记录，不会出现未归档就被清理的记录
+func apply(ctx context.Context, db *gorm.DB, cfg models.RetentionConfig, t target, res *Result) error {
+	if err := os.MkdirAll(cfg.ArchiveDir, 0o700); err != nil {
+		return err
+	}
+	pattern := fmt.Sprintf("%s-%s-%s", res.Rule, time.Now().UTC().Format("20060102T150405Z"), archivePattern)
+	f, err := os.CreateTemp(cfg.ArchiveDir, pattern)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	res.Archive = filepath.Base(f.Name())
+	gz := gzip.NewWriter(f)
+	enc := json.NewEncoder(gz)
+
+	batch := cfg.BatchSize
+	if batch <= 0 {
+		batch = 1000
+	}
+	for {
+		n, err := applyBatch(ctx, db, t, res, batch, func(rows []map[string]any) error {
+			for _, row := range rows {
+				if err := enc.Encode(row); err != nil {
+					return err
+				}
+			}
+			if err := gz.Flush(); err != nil {
+				return err
+			}
+			return f.Sync()
+		})
+		if err != nil {
+			return err
+		}
+		res.Processed += int64(n)
+		if n < batch {
+			break
+		}
+	}
+
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	// 记录都被其他实例处理了，不保留空归档
+	if res.Processed == 0 {
+		res.Archive = ""
+		return os.Remove(f.Name())
+	}
+	return f.Sync()
+}
+
+// archivedColumns 归档的列：删除时归档整行，匿名化时只归档主键与被清除的列，保留下来的列不必再复制一份
+func (t target) archivedColumns(action string) []string {
+	if action != ActionAnonymize {
+		return []string{"*"}
+	}
+	return append([]string{t.key}, slices.Sorted(maps.Keys(t.anonymize))...)
+}
+
+func applyBatch(ctx context.Context, db *gorm.DB, t target, res *Result, batch int, archive func([]map[string]any) error) (int, error) {
+	var n int
+	err := db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []map[string]any
+		err := t.scope(tx.Table(t.table).Select(t.archivedColumns(res.Action)), res.Cutoff).
+			Order(t.key).Limit(batch).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+		if err := archive(rows); err != nil {
+			return err
+		}
+
+		ids := make([]any, len(rows))
+		for i, row := range rows {
+			ids[i] = row[t.key]
+		}
+		q := tx.Table(t.table).Where(t.key+" IN ?", ids)
+		if res.Action == ActionAnonymize {
+			err = q.Updates(t.anonymize).Error
+		} else {
+			err = q.Delete(map[string]any{}).Error
+		}
+		if err != nil {
+			return err
+		}
+
+		n = len(rows)
+		return audit.RecordSystem(tx, audit.Entry{
+			Action:     "retention." + res.Action,
+			TargetType: t.table,
+			TargetId:   res.Rule,
+			After:      map[string]any{"count": n, "cutoff": res.Cutoff, "archive": res.Archive},
+		})
+	})
+	return n, err
+}
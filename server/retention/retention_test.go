@@ -0,0 +1,55 @@
+package retention
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Duration{
+		"packs-old.jsonl.gz":    -31 * 24 * time.Hour,
+		"packs-recent.jsonl.gz": -time.Hour,
+		"notes.txt":             -365 * 24 * time.Hour,
+	}
+	for name, age := range files {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(age), now.Add(age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := Prune(dir, 30*24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(removed, []string{"packs-old.jsonl.gz"}) {
+		t.Errorf("Prune() = %v, want [packs-old.jsonl.gz]", removed)
+	}
+	for name, want := range map[string]bool{"packs-old.jsonl.gz": false, "packs-recent.jsonl.gz": true, "notes.txt": true} {
+		if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != want {
+			t.Errorf("%s exists = %v, want %v", name, err == nil, want)
+		}
+	}
+
+	if removed, err := Prune(dir, 0, now.Add(365*24*time.Hour)); err != nil || removed != nil {
+		t.Errorf("Prune(maxAge=0) = %v, %v, want nothing removed", removed, err)
+	}
+}
+
+func TestArchivedColumns(t *testing.T) {
+	packs := targets["packs"]
+	if got := packs.archivedColumns(ActionAnonymize); !slices.Equal(got, []string{"pack_id", "pickup_code", "tracking_number", "user_id"}) {
+		t.Errorf("archivedColumns(anonymize) = %v", got)
+	}
+	if got := packs.archivedColumns(ActionDelete); !slices.Equal(got, []string{"*"}) {
+		t.Errorf("archivedColumns(delete) = %v", got)
+	}
+}
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/controllers"
+	"github.com/yurin-kami/PackChann/workers"
+	"gorm.io/gorm"
+)
+
+// HealthRoutes 注册供 docker compose / k8s 使用的存活与就绪探针
+func HealthRoutes(db *gorm.DB, router *gin.Engine, manager *workers.Manager) {
+	router.GET("/healthz", controllers.Liveness())
+	router.GET("/readyz", controllers.Readiness(db, manager))
+}
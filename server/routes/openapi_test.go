@@ -0,0 +1,39 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/docs"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/workers"
+)
+
+var ginParam = regexp.MustCompile(`[:*]([A-Za-z0-9_]+)`)
+
+func TestOpenAPICoversAllRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(docs.Spec, &spec); err != nil {
+		t.Fatalf("openapi.json is not valid JSON: %v", err)
+	}
+
+	router := gin.New()
+	RegisterAll(nil, router, &models.Config{}, nil, nil, workers.NewManager(context.Background()), nil, nil)
+	// /metrics 由 main 按配置注册
+	router.GET("/metrics", func(c *gin.Context) {})
+
+	for _, route := range router.Routes() {
+		path := ginParam.ReplaceAllString(route.Path, "{$1}")
+		if _, ok := spec.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is registered but missing from docs/openapi.json", route.Method, path)
+		}
+	}
+}
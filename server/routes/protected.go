@@ -1,35 +1,44 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/yurin-kami/PackChann/controllers"
+	"github.com/yurin-kami/PackChann/keyring"
 	"github.com/yurin-kami/PackChann/middlewares"
 	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
 	"gorm.io/gorm"
 )
 
-func ProtectedRoutes(db *gorm.DB, router *gin.Engine, jwtCfg models.JWTConfig) {
+// ProtectedRoutes 旧版动词风格路由，已弃用，保留到 sunset 之后移除，新功能只加在 V1Routes
+func ProtectedRoutes(db *gorm.DB, router *gin.Engine, cfg *models.Config, keys *keyring.Keyring, notifier *notify.Notifier, sunset time.Time) {
+	deprecated := func(successor string) gin.HandlerFunc {
+		return middlewares.DeprecationMiddleware(successor, sunset)
+	}
+
 	protected := router.Group("/")
-	protected.Use(middlewares.AuthMiddleware(db, jwtCfg))
+	protected.Use(middlewares.AuthMiddleware(db, keys))
 	{
-		protected.GET("/getPackDetails/:pack_id", controllers.GetPackDetailsByPackId(db))
-		protected.POST("/packCheckIn", controllers.CheckInPack(db))
-		protected.POST("/packCheckout", controllers.CheckOutPack(db))
-		protected.POST("/mailPack", controllers.MailPack(db))
-		protected.POST("/cancelMail", controllers.CancelMailPack(db))
-		protected.POST("/updatePackStatus", controllers.UpdatePackStatus(db))
-		protected.GET("/allPacks/:user_id", controllers.GetAllPacksByUserId(db))
-		protected.POST("/updateUserInfo", controllers.UpdateUserInfoByPhone(db))
+		protected.GET("/getPackDetails/:pack_id", deprecated("/api/v1/packs/{pack_id}"), controllers.GetPackDetailsByPackId(db))
+		protected.POST("/packCheckIn", deprecated("/api/v1/packs"), controllers.CheckInPack(db, notifier))
+		protected.POST("/packCheckout", deprecated("/api/v1/packs/{pack_id}/checkout"), controllers.CheckOutPack(db, cfg.StorageFee))
+		protected.POST("/mailPack", deprecated("/api/v1/mail-packs"), controllers.MailPack(db))
+		protected.POST("/cancelMail", deprecated("/api/v1/mail-packs/{pack_id}/cancel"), controllers.CancelMailPack(db))
+		protected.POST("/updatePackStatus", deprecated("/api/v1/packs/{pack_id}/status"), controllers.UpdatePackStatus(db))
+		protected.GET("/allPacks/:user_id", deprecated("/api/v1/users/{user_id}/packs"), controllers.GetAllPacksByUserId(db))
+		protected.POST("/updateUserInfo", deprecated("/api/v1/users/{user_id}"), controllers.UpdateUserInfoByPhone(db, cfg.MFA))
 
 		// Admin routes
 		admin := protected.Group("/admin")
-		admin.Use(middlewares.AdminMiddleware())
+		admin.Use(middlewares.AdminMiddleware(), middlewares.RequireMFAMiddleware(cfg.MFA))
 		{
-			admin.GET("/users", controllers.GetAllUsers(db))
-			admin.GET("/packs", controllers.GetAllPacks(db))
-			admin.PUT("/pack", controllers.AdminUpdatePack(db))
-			admin.DELETE("/deleteUser/:user_id", controllers.DeleteUser(db))
-			admin.GET("/usage", controllers.GetSystemStatus())
+			admin.GET("/users", deprecated("/api/v1/admin/users"), controllers.GetAllUsers(db))
+			admin.GET("/packs", deprecated("/api/v1/admin/packs"), controllers.GetAllPacks(db, cfg.Overdue))
+			admin.PUT("/pack", deprecated("/api/v1/admin/packs/{pack_id}"), controllers.AdminUpdatePack(db))
+			admin.DELETE("/deleteUser/:user_id", deprecated("/api/v1/admin/users/{user_id}"), controllers.DeleteUser(db))
+			admin.GET("/usage", deprecated("/api/v1/admin/usage"), controllers.GetSystemStatus())
 		}
 	}
 }
@@ -0,0 +1,36 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/controllers"
+	"github.com/yurin-kami/PackChann/docs"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
+	"github.com/yurin-kami/PackChann/ratelimit"
+	"github.com/yurin-kami/PackChann/sso"
+	"github.com/yurin-kami/PackChann/workers"
+	"gorm.io/gorm"
+)
+
+// RegisterAll 注册全部路由，main 与 OpenAPI 覆盖测试共用，避免两边注册的路由不一致
+func RegisterAll(db *gorm.DB, router *gin.Engine, cfg *models.Config, keys *keyring.Keyring, notifier *notify.Notifier, manager *workers.Manager, limiter *ratelimit.Limiter, providers *sso.Registry) {
+	// 健康检查
+	HealthRoutes(db, router, manager)
+
+	// 接口文档
+	router.GET("/openapi.json", docs.OpenAPI())
+	router.GET("/docs", docs.SwaggerUI())
+	router.GET("/docs/swagger-ui/:file", docs.SwaggerAsset())
+
+	// Token 签名公钥，供其他服务验证本服务签发的 Token
+	router.GET("/.well-known/jwks.json", controllers.JWKS(keys))
+
+	// /api/v1 资源路由
+	V1Routes(db, router, cfg, keys, notifier, limiter, providers)
+
+	// 旧版路由 (已弃用，响应带 Deprecation 头)
+	sunset := cfg.API.LegacySunsetTime()
+	UnprotectedRoutes(db, router, cfg, keys, limiter, sunset)
+	ProtectedRoutes(db, router, cfg, keys, notifier, sunset)
+}
@@ -2,17 +2,21 @@ package routes
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/yurin-kami/PackChann/controllers"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/middlewares"
 	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/ratelimit"
 	"gorm.io/gorm"
 )
 
-func UnprotectedRoutes(db *gorm.DB, router *gin.Engine, jwtCfg models.JWTConfig) {
+func UnprotectedRoutes(db *gorm.DB, router *gin.Engine, cfg *models.Config, keys *keyring.Keyring, limiter *ratelimit.Limiter, sunset time.Time) {
 	// Define your unprotected routes here
-	router.POST("/register", controllers.RegisterUser(db, jwtCfg))
-	router.POST("/login", controllers.LoginUser(db, jwtCfg))
+	router.POST("/register", middlewares.DeprecationMiddleware("/api/v1/auth/register", sunset), middlewares.RateLimitMiddleware(limiter, "register"), controllers.RegisterUser(db, keys, cfg.Password))
+	router.POST("/login", middlewares.DeprecationMiddleware("/api/v1/auth/login", sunset), middlewares.RateLimitMiddleware(limiter, "login"), controllers.LoginUser(db, keys, cfg.MFA, limiter))
 	router.GET("/ping", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "pong"})
 	})
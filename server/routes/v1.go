@@ -0,0 +1,94 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/controllers"
+	"github.com/yurin-kami/PackChann/keyring"
+	"github.com/yurin-kami/PackChann/middlewares"
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/notify"
+	"github.com/yurin-kami/PackChann/ratelimit"
+	"github.com/yurin-kami/PackChann/sso"
+	"gorm.io/gorm"
+)
+
+// V1Routes 注册 /api/v1 资源风格路由，与旧路由共用同一组处理函数
+func V1Routes(db *gorm.DB, router *gin.Engine, cfg *models.Config, keys *keyring.Keyring, notifier *notify.Notifier, limiter *ratelimit.Limiter, providers *sso.Registry) {
+	v1 := router.Group("/api/v1")
+	v1.GET("/healthz", controllers.Liveness())
+
+	auth := v1.Group("/auth")
+	{
+		auth.POST("/register", middlewares.RateLimitMiddleware(limiter, "register"), controllers.RegisterUser(db, keys, cfg.Password))
+		auth.POST("/login", middlewares.RateLimitMiddleware(limiter, "login"), controllers.LoginUser(db, keys, cfg.MFA, limiter))
+		auth.POST("/login/mfa", middlewares.RateLimitMiddleware(limiter, "login-mfa"), controllers.LoginMFA(db, keys, limiter))
+		auth.POST("/mfa/enroll", middlewares.RateLimitMiddleware(limiter, "login-mfa"), controllers.EnrollMFAWithToken(db, keys, cfg.MFA))
+		auth.POST("/mfa/activate", middlewares.RateLimitMiddleware(limiter, "login-mfa"), controllers.ActivateMFAWithToken(db, keys, cfg.MFA, limiter))
+		auth.GET("/sso/providers", controllers.SSOProviders(providers))
+		auth.GET("/sso/:provider/login", controllers.SSOLogin(providers))
+		auth.GET("/sso/:provider/callback", controllers.SSOCallback(db, providers, cfg.SSO))
+		auth.POST("/sso/exchange", middlewares.RateLimitMiddleware(limiter, "sso"), controllers.SSOExchange(db, keys, cfg.MFA))
+		auth.POST("/password/forgot", middlewares.RateLimitMiddleware(limiter, "forgot-password"), controllers.ForgotPassword(db, notifier, cfg.Password))
+		auth.POST("/password/reset", middlewares.RateLimitMiddleware(limiter, "reset-password"), controllers.ResetPassword(db, cfg.Password))
+	}
+
+	// 站点设备可以用带对应权限的 API Key 调用，也接受用户 Token
+	scoped := func(scope string) gin.HandlerFunc {
+		return middlewares.ScopedAuthMiddleware(db, keys, scope)
+	}
+	v1.POST("/packs", scoped(models.ScopePackCheckIn), controllers.CheckInPack(db, notifier))
+	v1.GET("/packs/:pack_id", scoped(models.ScopePackRead), controllers.GetPackDetailsByPackId(db))
+	v1.POST("/packs/:pack_id/checkout", scoped(models.ScopePackCheckOut), controllers.CheckOutPack(db, cfg.StorageFee))
+	v1.PUT("/packs/:pack_id/status", scoped(models.ScopePackStatus), controllers.UpdatePackStatus(db))
+
+	protected := v1.Group("/")
+	protected.Use(middlewares.AuthMiddleware(db, keys))
+	{
+		protected.POST("/mail-packs", controllers.MailPack(db))
+		protected.POST("/mail-packs/:pack_id/cancel", controllers.CancelMailPack(db))
+
+		protected.GET("/users/:user_id/packs", controllers.GetAllPacksByUserId(db))
+		protected.PATCH("/users/:user_id", controllers.UpdateUserInfoByPhone(db, cfg.MFA))
+		protected.PUT("/users/:user_id/password", controllers.ChangePassword(db, cfg.Password))
+		protected.POST("/users/:user_id/mfa", controllers.EnrollMFA(db, cfg.MFA))
+		protected.POST("/users/:user_id/mfa/activate", controllers.ActivateMFA(db, cfg.MFA, limiter))
+		protected.DELETE("/users/:user_id/mfa", controllers.DisableMFA(db, cfg.MFA, limiter))
+		protected.POST("/users/:user_id/mfa/recovery-codes", controllers.RegenerateRecoveryCodes(db, cfg.MFA, limiter))
+		protected.GET("/users/:user_id/export", controllers.ExportUserData(db))
+		protected.POST("/users/:user_id/deletion", controllers.RequestDeletion(db, cfg.Privacy))
+		protected.DELETE("/users/:user_id/deletion", controllers.CancelDeletion(db))
+
+		admin := protected.Group("/admin")
+		admin.Use(middlewares.AdminMiddleware(), middlewares.RequireMFAMiddleware(cfg.MFA))
+		{
+			admin.GET("/users", controllers.GetAllUsers(db))
+			admin.GET("/users/:user_id/deletion-check", controllers.UserDeletionCheck(db))
+			admin.DELETE("/users/:user_id", controllers.DeleteUser(db))
+			admin.POST("/users/:user_id/restore", controllers.RestoreUser(db))
+			admin.POST("/users/:user_id/unlock", controllers.UnlockUser(db, limiter))
+			admin.DELETE("/users/:user_id/mfa", controllers.AdminResetMFA(db))
+			admin.PUT("/users/:user_id/role", controllers.UpdateUserRole(db))
+			admin.GET("/packs", controllers.GetAllPacks(db, cfg.Overdue))
+			admin.GET("/packs/overdue", controllers.GetOverduePacks(db, cfg.Overdue))
+			admin.PATCH("/packs/:pack_id", controllers.AdminUpdatePack(db))
+			admin.DELETE("/packs/:pack_id", controllers.DeletePack(db))
+			admin.POST("/packs/:pack_id/restore", controllers.RestorePack(db))
+			admin.GET("/packs/:pack_id/fees", controllers.GetPackFees(db, cfg.StorageFee))
+			admin.POST("/packs/:pack_id/fee-waiver", controllers.WaiveStorageFee(db))
+			admin.POST("/packs/:pack_id/return", controllers.MarkPackReturn(db, notifier))
+			admin.DELETE("/packs/:pack_id/return", controllers.CancelPackReturn(db))
+			admin.GET("/return-manifests", controllers.GetReturnManifests(db))
+			admin.POST("/return-manifests", controllers.CreateReturnManifest(db))
+			admin.GET("/return-manifests/:manifest_id", controllers.GetReturnManifest(db))
+			admin.POST("/return-manifests/:manifest_id/handover", controllers.HandoverReturnManifest(db, notifier, cfg.Returns))
+			admin.GET("/return-manifests/:manifest_id/evidence", controllers.GetManifestEvidence(db))
+			admin.GET("/usage", controllers.GetSystemStatus())
+			admin.GET("/search", controllers.Search(db))
+			admin.GET("/api-keys", controllers.ListAPIKeys(db))
+			admin.POST("/api-keys", controllers.CreateAPIKey(db))
+			admin.DELETE("/api-keys/:key_id", controllers.RevokeAPIKey(db))
+			admin.GET("/audit-logs", controllers.GetAuditLogs(db))
+			admin.GET("/retention/report", controllers.RetentionReport(db, cfg.Retention))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/yurin-kami/PackChann/models"
+	"golang.org/x/oauth2"
+)
+
+type oidcProvider struct {
+	cfg models.SSOProviderConfig
+
+	mu       sync.Mutex
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+func newOIDCProvider(cfg models.SSOProviderConfig) *oidcProvider {
+	return &oidcProvider{cfg: cfg}
+}
+
+// setup 首次使用时做 discovery；失败不缓存，下次请求重试
+func (p *oidcProvider) setup(ctx context.Context) (*oauth2.Config, *oidc.IDTokenVerifier, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.oauth != nil {
+		return p.oauth, p.verifier, nil
+	}
+
+	provider, err := oidc.NewProvider(oidc.ClientContext(ctx, httpClient), p.cfg.Issuer)
+	if err != nil {
+		return nil, nil, fmt.Errorf("oidc discovery %s: %w", p.cfg.Issuer, err)
+	}
+	scopes := p.cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{oidc.ScopeOpenID, "profile"}
+	}
+	p.oauth = &oauth2.Config{
+		ClientID:     p.cfg.ClientID,
+		ClientSecret: p.cfg.ClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  p.cfg.RedirectURL,
+		Scopes:       scopes,
+	}
+	p.verifier = provider.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+	return p.oauth, p.verifier, nil
+}
+
+func (p *oidcProvider) AuthURL(ctx context.Context, state, verifier string) (string, error) {
+	oauth, _, err := p.setup(ctx)
+	if err != nil {
+		return "", err
+	}
+	// state 同时作为 nonce，回调时与 ID Token 中的 nonce 比对
+	return oauth.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier), oidc.Nonce(state)), nil
+}
+
+func (p *oidcProvider) Exchange(ctx context.Context, query url.Values, state, verifier string) (*Identity, error) {
+	if e := query.Get("error"); e != "" {
+		return nil, fmt.Errorf("oidc authorization failed: %s %s", e, query.Get("error_description"))
+	}
+	code := query.Get("code")
+	if code == "" {
+		return nil, errors.New("oidc callback without code")
+	}
+
+	oauth, idVerifier, err := p.setup(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ctx = oidc.ClientContext(ctx, httpClient)
+	token, err := oauth.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("oidc token exchange: %w", err)
+	}
+	rawID, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("oidc token response without id_token")
+	}
+	idToken, err := idVerifier.Verify(ctx, rawID)
+	if err != nil {
+		return nil, fmt.Errorf("oidc id_token: %w", err)
+	}
+	if idToken.Nonce != state {
+		return nil, errors.New("oidc id_token nonce mismatch")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("oidc claims: %w", err)
+	}
+	return &Identity{
+		Provider:  p.cfg.Name,
+		Subject:   idToken.Subject,
+		StudentId: claimString(claims, p.cfg.StudentIdClaim),
+		Name:      claimString(claims, p.cfg.NameClaim),
+		Phone:     claimString(claims, p.cfg.PhoneClaim),
+	}, nil
+}
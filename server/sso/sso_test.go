@@ -0,0 +1,125 @@
+package sso_test
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/yurin-kami/PackChann/models"
+	"github.com/yurin-kami/PackChann/sso"
+	"github.com/yurin-kami/PackChann/sso/ssotest"
+	"golang.org/x/oauth2"
+)
+
+const callback = "http://packchann.test/api/v1/auth/sso/campus/callback"
+
+var student = ssotest.User{
+	Subject: "u-42",
+	Claims:  map[string]string{"student_id": "20210001", "name": "张三", "phone": "13800000001"},
+}
+
+// follow 访问 IdP 登录地址，返回 IdP 重定向回来的回调参数
+func follow(t *testing.T, authURL string) url.Values {
+	t.Helper()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("expected redirect, got %d %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	return loc.Query()
+}
+
+func newRegistry(t *testing.T, idp *ssotest.IdP) *sso.Registry {
+	t.Helper()
+	r, err := sso.NewRegistry([]models.SSOProviderConfig{
+		{
+			Name: "campus", Type: "oidc", RedirectURL: callback,
+			Issuer: idp.Issuer(), ClientID: idp.ClientID, ClientSecret: idp.ClientSecret,
+			StudentIdClaim: "student_id", NameClaim: "name", PhoneClaim: "phone",
+		},
+		{
+			Name: "cas", Type: "cas", RedirectURL: callback, BaseURL: idp.CASBaseURL(),
+			NameClaim: "name", PhoneClaim: "phone",
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestOIDCWithPKCE(t *testing.T) {
+	idp := ssotest.NewIdP(student)
+	defer idp.Close()
+	p, _, _ := newRegistry(t, idp).Get("campus")
+	ctx := context.Background()
+
+	verifier := oauth2.GenerateVerifier()
+	authURL, err := p.AuthURL(ctx, "state-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := follow(t, authURL)
+	if query.Get("state") != "state-1" {
+		t.Fatalf("state not echoed: %v", query)
+	}
+
+	id, err := p.Exchange(ctx, query, "state-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sso.Identity{Provider: "campus", Subject: "u-42", StudentId: "20210001", Name: "张三", Phone: "13800000001"}
+	if *id != want {
+		t.Fatalf("identity = %+v, want %+v", *id, want)
+	}
+}
+
+func TestOIDCRejectsWrongVerifier(t *testing.T) {
+	idp := ssotest.NewIdP(student)
+	defer idp.Close()
+	p, _, _ := newRegistry(t, idp).Get("campus")
+	ctx := context.Background()
+
+	authURL, err := p.AuthURL(ctx, "state-1", oauth2.GenerateVerifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Exchange(ctx, follow(t, authURL), "state-1", oauth2.GenerateVerifier()); err == nil {
+		t.Fatal("exchange with a different code_verifier must fail")
+	}
+}
+
+func TestCASServiceValidate(t *testing.T) {
+	idp := ssotest.NewIdP(ssotest.User{Subject: "20210002", Claims: map[string]string{"name": "李四"}})
+	defer idp.Close()
+	p, _, _ := newRegistry(t, idp).Get("cas")
+	ctx := context.Background()
+
+	authURL, err := p.AuthURL(ctx, "state-2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := follow(t, authURL)
+	if query.Get("state") != "state-2" || query.Get("ticket") == "" {
+		t.Fatalf("unexpected callback query: %v", query)
+	}
+
+	id, err := p.Exchange(ctx, query, "state-2", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id.Subject != "20210002" || id.StudentId != "20210002" || id.Name != "李四" {
+		t.Fatalf("unexpected identity %+v", *id)
+	}
+
+	// 票据只能校验一次
+	if _, err := p.Exchange(ctx, query, "state-2", ""); err == nil {
+		t.Fatal("reused CAS ticket must be rejected")
+	}
+}
@@ -0,0 +1,249 @@
+// Package ssotest 提供本地模拟的 OIDC / CAS 身份提供方，用于测试与本地联调，不依赖真实的校园 IdP
+package ssotest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const keyID = "ssotest"
+
+// User 模拟 IdP 上已登录的用户。Claims 作为 ID Token 的附加 claim，同时作为 CAS 属性返回
+type User struct {
+	Subject string
+	Claims  map[string]string
+}
+
+type authRequest struct {
+	redirectURI string
+	challenge   string
+	nonce       string
+}
+
+// IdP 同时实现 OIDC（discovery、authorize、token、jwks）与 CAS（login、serviceValidate）。
+// 访问登录地址时直接以 User 身份通过认证并跳回回调地址
+type IdP struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+
+	mu      sync.Mutex
+	user    User
+	key     *rsa.PrivateKey
+	codes   map[string]authRequest
+	tickets map[string]string // ticket -> service
+}
+
+func NewIdP(user User) *IdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	idp := &IdP{
+		ClientID:     "packchann",
+		ClientSecret: "secret",
+		user:         user,
+		key:          key,
+		codes:        make(map[string]authRequest),
+		tickets:      make(map[string]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/authorize", idp.authorize)
+	mux.HandleFunc("/token", idp.token)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/cas/login", idp.casLogin)
+	mux.HandleFunc("/cas/serviceValidate", idp.casValidate)
+	mux.HandleFunc("/cas/p3/serviceValidate", idp.casValidate)
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+// Issuer OIDC issuer 地址
+func (idp *IdP) Issuer() string { return idp.Server.URL }
+
+// CASBaseURL CAS 服务地址
+func (idp *IdP) CASBaseURL() string { return idp.Server.URL + "/cas" }
+
+// SetUser 切换之后登录的用户
+func (idp *IdP) SetUser(user User) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.user = user
+}
+
+func (idp *IdP) Close() { idp.Server.Close() }
+
+func randomString() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (idp *IdP) discovery(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"issuer":                                idp.Issuer(),
+		"authorization_endpoint":                idp.Issuer() + "/authorize",
+		"token_endpoint":                        idp.Issuer() + "/token",
+		"jwks_uri":                              idp.Issuer() + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (idp *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("client_id") != idp.ClientID || q.Get("response_type") != "code" || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid authorization request", http.StatusBadRequest)
+		return
+	}
+	code := randomString()
+	idp.mu.Lock()
+	idp.codes[code] = authRequest{redirectURI: q.Get("redirect_uri"), challenge: q.Get("code_challenge"), nonce: q.Get("nonce")}
+	idp.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	params := redirect.Query()
+	params.Set("code", code)
+	params.Set("state", q.Get("state"))
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *IdP) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+	clientID, clientSecret, ok := r.BasicAuth()
+	if !ok {
+		clientID, clientSecret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+	if clientID != idp.ClientID || clientSecret != idp.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	idp.mu.Lock()
+	req, found := idp.codes[r.PostForm.Get("code")]
+	delete(idp.codes, r.PostForm.Get("code"))
+	user := idp.user
+	idp.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !found || req.redirectURI != r.PostForm.Get("redirect_uri") || base64.RawURLEncoding.EncodeToString(sum[:]) != req.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":   idp.Issuer(),
+		"sub":   user.Subject,
+		"aud":   idp.ClientID,
+		"iat":   now.Unix(),
+		"exp":   now.Add(5 * time.Minute).Unix(),
+		"nonce": req.nonce,
+	}
+	for k, v := range user.Claims {
+		claims[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = keyID
+	idToken, err := token.SignedString(idp.key)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idToken,
+	})
+}
+
+func (idp *IdP) jwks(w http.ResponseWriter, _ *http.Request) {
+	pub := idp.key.PublicKey
+	writeJSON(w, http.StatusOK, map[string]any{"keys": []map[string]string{{
+		"kty": "RSA",
+		"kid": keyID,
+		"use": "sig",
+		"alg": "RS256",
+		"n":   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+		"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+	}}})
+}
+
+func (idp *IdP) casLogin(w http.ResponseWriter, r *http.Request) {
+	service := r.URL.Query().Get("service")
+	redirect, err := url.Parse(service)
+	if err != nil || service == "" {
+		http.Error(w, "invalid service", http.StatusBadRequest)
+		return
+	}
+	ticket := "ST-" + randomString()
+	idp.mu.Lock()
+	idp.tickets[ticket] = service
+	idp.mu.Unlock()
+
+	params := redirect.Query()
+	params.Set("ticket", ticket)
+	redirect.RawQuery = params.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (idp *IdP) casValidate(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	idp.mu.Lock()
+	service, found := idp.tickets[q.Get("ticket")]
+	delete(idp.tickets, q.Get("ticket"))
+	user := idp.user
+	idp.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/xml")
+	if !found || service != q.Get("service") {
+		fmt.Fprintf(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas"><cas:authenticationFailure code="INVALID_TICKET">ticket %s not recognized</cas:authenticationFailure></cas:serviceResponse>`, xmlEscape(q.Get("ticket")))
+		return
+	}
+
+	fmt.Fprint(w, `<cas:serviceResponse xmlns:cas="http://www.yale.edu/tp/cas"><cas:authenticationSuccess>`)
+	fmt.Fprintf(w, `<cas:user>%s</cas:user><cas:attributes>`, xmlEscape(user.Subject))
+	for k, v := range user.Claims {
+		fmt.Fprintf(w, `<cas:%s>%s</cas:%s>`, k, xmlEscape(v), k)
+	}
+	fmt.Fprint(w, `</cas:attributes></cas:authenticationSuccess></cas:serviceResponse>`)
+}
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+// apiKeyPrefix 便于在日志和代码仓库扫描中识别泄露的 API Key
+const apiKeyPrefix = "pck_"
+
+// NewAPIKey 生成 256 位随机 API Key，返回明文、用于展示的前缀和保存到数据库的哈希
+func NewAPIKey() (key, prefix, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", err
+	}
+	key = apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return key, key[:len(apiKeyPrefix)+8], HashAPIKey(key), nil
+}
+
+// HashAPIKey API Key 熵足够高，直接用 SHA-256 即可，验证时按哈希查找
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// NewSSOTicket 生成 256 位随机 SSO 票据，返回明文和保存到数据库的哈希
+func NewSSOTicket() (ticket, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	ticket = base64.RawURLEncoding.EncodeToString(buf)
+	return ticket, HashAPIKey(ticket), nil
+}
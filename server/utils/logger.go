@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/yurin-kami/PackChann/models"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// NewLogger 根据配置创建 slog 日志器，Format 为 "text" 时输出可读文本，否则输出 JSON
+func NewLogger(cfg models.LogConfig) *slog.Logger {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Level)); err != nil {
+		level = slog.LevelInfo
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	if strings.EqualFold(cfg.Format, "text") {
+		return slog.New(slog.NewTextHandler(os.Stdout, opts))
+	}
+	return slog.New(slog.NewJSONHandler(os.Stdout, opts))
+}
+
+// Logger 返回当前请求的日志器，自动附带请求 ID、路由与用户 ID
+func Logger(c *gin.Context) *slog.Logger {
+	logger := slog.Default()
+	if l, ok := c.Get("logger"); ok {
+		logger = l.(*slog.Logger)
+	}
+
+	attrs := []any{"request_id", c.GetString("request_id"), "route", c.FullPath()}
+	if userId, ok := c.Get("user_id"); ok {
+		attrs = append(attrs, "user_id", userId)
+	}
+	if sc := trace.SpanContextFromContext(c.Request.Context()); sc.IsValid() {
+		attrs = append(attrs, "trace_id", sc.TraceID().String())
+	}
+	return logger.With(attrs...)
+}
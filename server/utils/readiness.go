@@ -0,0 +1,15 @@
+package utils
+
+import "sync/atomic"
+
+var ready atomic.Bool
+
+// SetReady 设置服务是否可以接收新流量，关闭前会先置为 false
+func SetReady(v bool) {
+	ready.Store(v)
+}
+
+// IsReady 返回服务当前是否就绪
+func IsReady() bool {
+	return ready.Load()
+}
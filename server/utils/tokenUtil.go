@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/yurin-kami/PackChann/keyring"
 	"github.com/yurin-kami/PackChann/models"
 )
 
@@ -15,8 +16,9 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-func GenerateAllToken(user models.User, cfg models.JWTConfig) (string, string, error) {
-	expirationTime := time.Now().Add(time.Duration(cfg.ExpirationHours) * time.Hour)
+// GenerateAllToken 用当前签名密钥签发 access token 与 refresh token
+func GenerateAllToken(user models.User, keys *keyring.Keyring) (string, string, error) {
+	expirationTime := time.Now().Add(keys.Config().TokenTTL())
 
 	claims := &Claims{
 		UserId:    fmt.Sprint(user.UserId),
@@ -29,8 +31,7 @@ func GenerateAllToken(user models.User, cfg models.JWTConfig) (string, string, e
 		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signedToken, err := token.SignedString([]byte(cfg.Secret))
+	signedToken, err := keys.Sign(claims)
 	if err != nil {
 		return "", "", err
 	}
@@ -45,11 +46,50 @@ func GenerateAllToken(user models.User, cfg models.JWTConfig) (string, string, e
 			Issuer:    "PackChann",
 		},
 	}
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, refreshClaims)
-	signedRefreshToken, err := refreshToken.SignedString([]byte(cfg.Secret))
+	signedRefreshToken, err := keys.Sign(refreshClaims)
 	if err != nil {
 		return "", "", err
 	}
 
 	return signedToken, signedRefreshToken, nil
 }
+
+// 临时 Token 的用途，只能用于对应的登录步骤，不写入 user_tokens，AuthMiddleware 不会接受
+const (
+	// PurposeMFA 密码校验通过但尚未完成两步验证
+	PurposeMFA = "mfa"
+)
+
+type PurposeClaims struct {
+	UserId  string
+	Purpose string
+	jwt.RegisteredClaims
+}
+
+// GeneratePurposeToken 签发只能用于 purpose 的短期 Token
+func GeneratePurposeToken(user models.User, keys *keyring.Keyring, purpose string, ttl time.Duration) (string, error) {
+	claims := &PurposeClaims{
+		UserId:  fmt.Sprint(user.UserId),
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "PackChann",
+			// 公钥对外公布后其他服务也能验证本服务的 Token，用 aud 区分临时 Token，避免被当作正式 Token 接受
+			Audience: jwt.ClaimStrings{"PackChann:" + purpose},
+		},
+	}
+	return keys.Sign(claims)
+}
+
+// ParsePurposeToken 校验 GeneratePurposeToken 签发的 Token 及其用途，返回其中的用户 ID
+func ParsePurposeToken(tokenString string, keys *keyring.Keyring, purpose string) (string, error) {
+	claims := &PurposeClaims{}
+	if err := keys.Parse(tokenString, claims); err != nil {
+		return "", fmt.Errorf("invalid %s token: %w", purpose, err)
+	}
+	if claims.Purpose != purpose {
+		return "", fmt.Errorf("invalid %s token: wrong purpose %q", purpose, claims.Purpose)
+	}
+	return claims.UserId, nil
+}
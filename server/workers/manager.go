@@ -0,0 +1,101 @@
+package workers
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Manager 管理后台任务的生命周期，关闭时统一取消并等待全部退出
+type Manager struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu      sync.RWMutex
+	running map[string]bool
+}
+
+func NewManager(parent context.Context) *Manager {
+	ctx, cancel := context.WithCancel(parent)
+	return &Manager{ctx: ctx, cancel: cancel, running: make(map[string]bool)}
+}
+
+// Go 启动一个后台任务，fn 应在 ctx 取消后尽快返回
+func (m *Manager) Go(name string, fn func(ctx context.Context)) {
+	m.setRunning(name, true)
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer m.setRunning(name, false)
+		defer func() {
+			if r := recover(); r != nil {
+				slog.Error("后台任务 panic，已停止", "job", name, "panic", r, "stack", string(debug.Stack()))
+			}
+		}()
+		fn(m.ctx)
+	}()
+}
+
+func (m *Manager) setRunning(name string, v bool) {
+	m.mu.Lock()
+	m.running[name] = v
+	m.mu.Unlock()
+}
+
+// Status 返回每个已注册后台任务当前是否仍在运行
+func (m *Manager) Status() map[string]bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	status := make(map[string]bool, len(m.running))
+	for name, v := range m.running {
+		status[name] = v
+	}
+	return status
+}
+
+// Every 按固定间隔执行 fn，直到 Manager 停止
+func (m *Manager) Every(name string, interval time.Duration, fn func(ctx context.Context)) {
+	m.Go(name, func(ctx context.Context) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				runOnce(ctx, name, fn)
+			}
+		}
+	})
+}
+
+// runOnce 执行一次周期任务，单次 panic 只记录日志，不影响下一轮执行
+func runOnce(ctx context.Context, name string, fn func(ctx context.Context)) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("后台任务 panic", "job", name, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+	fn(ctx)
+}
+
+// Stop 取消所有后台任务并等待退出，超过 ctx 期限则返回 ctx 错误
+func (m *Manager) Stop(ctx context.Context) error {
+	m.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -3,7 +3,7 @@ import type { AxiosInstance, InternalAxiosRequestConfig, AxiosResponse } from 'a
 
 // 创建axios实例
 const apiClient: AxiosInstance = axios.create({
-  baseURL: '/api', // 使用Vite代理，开发环境自动转发到后端
+  baseURL: '/api/v1', // 使用Vite代理，开发环境自动转发到后端
   timeout: 10000,
   headers: {
     'Content-Type': 'application/json'
@@ -3,6 +3,24 @@ import type {
   LoginRequest,
   RegisterRequest,
   AuthResponse,
+  MFAChallenge,
+  MFALoginRequest,
+  MFAEnrollment,
+  SSOProvider,
+  APIKey,
+  UserDeletionCheck,
+  OverduePack,
+  OverdueQuery,
+  ReturnManifest,
+  StorageFee,
+  PackFees,
+  CreateManifestRequest,
+  ManifestListQuery,
+  HandoverRequest,
+  DeleteUserParams,
+  AuditLog,
+  AuditLogQuery,
+  CreateAPIKeyRequest,
   User,
   Pack,
   PackCheckInRequest,
@@ -11,77 +29,212 @@ import type {
   CancelMailRequest,
   UpdatePackStatusRequest,
   UpdateUserInfoRequest,
-  ApiResponse
+  ChangePasswordRequest,
+  ForgotPasswordRequest,
+  ResetPasswordRequest,
+  ApiResponse,
+  PageResponse,
+  SearchResult,
+  PackListQuery,
+  UserListQuery
 } from '@/types'
 
 // ============ 认证相关 ============
 export const authApi = {
   // 用户登录
   login: (data: LoginRequest) => 
-    apiClient.post<AuthResponse>('/login', data),
+    apiClient.post<AuthResponse | MFAChallenge>('/auth/login', data),
+
+  // 登录第二步：提交验证码或恢复码
+  loginMFA: (data: MFALoginRequest) => 
+    apiClient.post<AuthResponse>('/auth/login/mfa', data),
+
+  // 首次登录绑定验证器
+  enrollMFA: (mfaToken: string) => 
+    apiClient.post<MFAEnrollment>('/auth/mfa/enroll', { mfa_token: mfaToken }),
+
+  // 确认绑定并完成登录
+  activateMFA: (data: MFALoginRequest) => 
+    apiClient.post<AuthResponse & { recovery_codes: string[] }>('/auth/mfa/activate', data),
 
   // 用户注册
   register: (data: RegisterRequest) => 
-    apiClient.post<AuthResponse>('/register', data),
+    apiClient.post<AuthResponse>('/auth/register', data),
+
+  // 可用的统一身份认证方式
+  ssoProviders: () => 
+    apiClient.get<{ providers: SSOProvider[] }>('/auth/sso/providers'),
+
+  // 统一身份认证登录入口，需整页跳转
+  ssoLoginURL: (provider: string) => 
+    `${apiClient.defaults.baseURL}/auth/sso/${encodeURIComponent(provider)}/login`,
+
+  // 用回调票据换取登录结果
+  ssoExchange: (ticket: string) => 
+    apiClient.post<AuthResponse | MFAChallenge>('/auth/sso/exchange', { ticket }),
+
+  // 申请找回密码验证码
+  forgotPassword: (data: ForgotPasswordRequest) => 
+    apiClient.post<ApiResponse>('/auth/password/forgot', data),
+
+  // 使用验证码重置密码
+  resetPassword: (data: ResetPasswordRequest) => 
+    apiClient.post<ApiResponse>('/auth/password/reset', data),
 
   // 健康检查
   ping: () => 
-    apiClient.get('/ping')
+    apiClient.get('/healthz')
 }
 
 // ============ 包裹相关 ============
 export const packApi = {
   // 包裹入库
   checkIn: (data: PackCheckInRequest) => 
-    apiClient.post<ApiResponse>('/packCheckIn', data),
+    apiClient.post<ApiResponse>('/packs', data),
 
   // 包裹出库
   checkOut: (data: PackCheckOutRequest) => 
-    apiClient.post<ApiResponse>('/packCheckout', data),
+    apiClient.post<{ message: string; pack: Pack; storage_fee: StorageFee | null }>(`/packs/${data.pack_id}/checkout`, data),
 
   // 创建寄件
   mailPack: (data: MailPackRequest) => 
-    apiClient.post<ApiResponse>('/mailPack', data),
+    apiClient.post<ApiResponse>('/mail-packs', data),
 
   // 取消寄件
   cancelMail: (data: CancelMailRequest) => 
-    apiClient.post<ApiResponse>('/cancelMail', data),
+    apiClient.post<ApiResponse>(`/mail-packs/${data.pack_id}/cancel`, data),
 
   // 更新包裹状态
   updateStatus: (data: UpdatePackStatusRequest) => 
-    apiClient.post<ApiResponse>('/updatePackStatus', data),
+    apiClient.put<ApiResponse>(`/packs/${data.pack_id}/status`, data),
 
   // 获取包裹详情
   getDetails: (packId: number) => 
-    apiClient.get<ApiResponse>(`/getPackDetails/${packId}`),
+    apiClient.get<ApiResponse>(`/packs/${packId}`),
 
   // 获取用户所有包裹
   getAllByUser: (userId: number) => 
-    apiClient.get<ApiResponse>(`/allPacks/${userId}`)
+    apiClient.get<ApiResponse>(`/users/${userId}/packs`)
 }
 
 // ============ 用户相关 ============
 export const userApi = {
   // 更新用户信息
   updateInfo: (data: UpdateUserInfoRequest) => 
-    apiClient.post<ApiResponse>('/updateUserInfo', data)
+    apiClient.patch<ApiResponse>(`/users/${data.user_id}`, data),
+
+  // 修改密码
+  changePassword: (data: ChangePasswordRequest) => 
+    apiClient.put<ApiResponse>(`/users/${data.user_id}/password`, data),
+
+  // 导出个人数据（ZIP 或 JSON 文件）
+  exportData: (userId: number, format: 'zip' | 'json' = 'zip') => 
+    apiClient.get<Blob>(`/users/${userId}/export`, { params: { format }, responseType: 'blob' }),
+
+  // 申请注销账号，冷静期结束后清除个人信息
+  requestDeletion: (userId: number, confirm: { password?: string; code?: string }) => 
+    apiClient.post<{ message: string; deletion_due_at: string }>(`/users/${userId}/deletion`, { user_id: userId, ...confirm }),
+
+  // 冷静期内撤销注销申请
+  cancelDeletion: (userId: number) => 
+    apiClient.delete<ApiResponse>(`/users/${userId}/deletion`)
 }
 
 // ============ 管理员相关 ============
 export const adminApi = {
-  // 获取所有用户
-  getAllUsers: () => 
-    apiClient.get<ApiResponse>('/admin/users'),
+  // 获取用户列表（分页）
+  getAllUsers: (params?: UserListQuery) => 
+    apiClient.get<PageResponse<'users', User>>('/admin/users', { params }),
 
-  // 获取所有包裹
-  getAllPacks: (status?: string) => 
-    apiClient.get<ApiResponse>('/admin/packs', { params: { status } }),
+  // 获取包裹列表（分页、筛选）
+  getAllPacks: (params?: PackListQuery) => 
+    apiClient.get<PageResponse<'packs', Pack>>('/admin/packs', { params }),
+
+  // 逾期报表，默认最久的在前
+  getOverduePacks: (params?: OverdueQuery) => 
+    apiClient.get<PageResponse<'packs', OverduePack> & { summary: Record<string, number> }>('/admin/packs/overdue', { params }),
 
   // 更新包裹信息
   updatePack: (data: Partial<Pack>) => 
-    apiClient.put<ApiResponse>('/admin/pack', data),
+    apiClient.patch<ApiResponse>(`/admin/packs/${data.pack_id}`, data),
+
+  // 删除用户前检查未取包裹与在线会话
+  userDeletionCheck: (userId: number) => 
+    apiClient.get<UserDeletionCheck>(`/admin/users/${userId}/deletion-check`),
+
+  // 删除用户（软删除），有未取包裹时需指定处理方式
+  deleteUser: (userId: number, params?: DeleteUserParams) => 
+    apiClient.delete<ApiResponse>(`/admin/users/${userId}`, { params }),
+
+  // 恢复已删除的用户
+  restoreUser: (userId: number) => 
+    apiClient.post<ApiResponse>(`/admin/users/${userId}/restore`),
+
+  // 删除包裹（软删除）
+  deletePack: (packId: number) => 
+    apiClient.delete<ApiResponse>(`/admin/packs/${packId}`),
+
+  // 恢复已删除的包裹
+  restorePack: (packId: number) => 
+    apiClient.post<ApiResponse>(`/admin/packs/${packId}/restore`),
+
+  // 包裹保管费流水
+  getPackFees: (packId: number) => 
+    apiClient.get<PackFees>(`/admin/packs/${packId}/fees`),
+
+  // 减免保管费，需填写原因
+  waiveStorageFee: (packId: number, reason: string) => 
+    apiClient.post<{ entry: StorageFee }>(`/admin/packs/${packId}/fee-waiver`, { reason }),
+
+  // 标记包裹待退回
+  markPackReturn: (packId: number) => 
+    apiClient.post<{ pack: Pack }>(`/admin/packs/${packId}/return`),
+
+  // 撤销退回，包裹恢复为待取
+  cancelPackReturn: (packId: number) => 
+    apiClient.delete<{ pack: Pack }>(`/admin/packs/${packId}/return`),
+
+  // 退件交接单
+  getReturnManifests: (params?: ManifestListQuery) => 
+    apiClient.get<PageResponse<'manifests', ReturnManifest>>('/admin/return-manifests', { params }),
+
+  getReturnManifest: (manifestId: number) => 
+    apiClient.get<{ manifest: ReturnManifest; packs: Pack[] }>(`/admin/return-manifests/${manifestId}`),
+
+  createReturnManifest: (data: CreateManifestRequest) => 
+    apiClient.post<{ manifest: ReturnManifest; packs: Pack[] }>('/admin/return-manifests', data),
+
+  handoverReturnManifest: (manifestId: number, data: HandoverRequest) => {
+    const form = new FormData()
+    form.append('courier_name', data.courier_name)
+    form.append('courier_phone', data.courier_phone || '')
+    form.append('evidence_type', data.evidence_type)
+    form.append('evidence', data.evidence)
+    return apiClient.post<{ manifest: ReturnManifest; packs: Pack[] }>(`/admin/return-manifests/${manifestId}/handover`, form)
+  },
+
+  getManifestEvidence: (manifestId: number) => 
+    apiClient.get<Blob>(`/admin/return-manifests/${manifestId}/evidence`, { responseType: 'blob' }),
+
+  // 解除账号登录锁定
+  unlockUser: (userId: number) => 
+    apiClient.post<ApiResponse>(`/admin/users/${userId}/unlock`),
+
+  // 综合搜索用户与包裹
+  search: (q: string, limit?: number) => 
+    apiClient.get<SearchResult>('/admin/search', { params: { q, limit } }),
+
+  // API Key 管理
+  listApiKeys: () => 
+    apiClient.get<{ api_keys: APIKey[] }>('/admin/api-keys'),
+
+  createApiKey: (data: CreateAPIKeyRequest) => 
+    apiClient.post<{ api_key: APIKey; key: string }>('/admin/api-keys', data),
+
+  revokeApiKey: (keyId: number) => 
+    apiClient.delete<ApiResponse>(`/admin/api-keys/${keyId}`),
 
-  // 删除用户
-  deleteUser: (userId: number) => 
-    apiClient.delete<ApiResponse>('/admin/deleteUser', { params: { user_id: userId } })
+  // 查询审计记录（分页、筛选）
+  getAuditLogs: (params?: AuditLogQuery) => 
+    apiClient.get<PageResponse<'audit_logs', AuditLog>>('/admin/audit-logs', { params })
 }
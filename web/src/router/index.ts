@@ -16,6 +16,13 @@ const router = createRouter({
       component: () => import('@/views/LoginPage.vue'),
       meta: { public: true }
     },
+    {
+      // 统一身份认证回调，由登录页读取 fragment 中的票据完成登录
+      path: '/sso/callback',
+      name: 'sso-callback',
+      component: () => import('@/views/LoginPage.vue'),
+      meta: { public: true }
+    },
     {
       path: '/register',
       name: 'register',
@@ -2,7 +2,7 @@ import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
 import type { User } from '@/types'
 import { authApi } from '@/api'
-import type { LoginRequest, RegisterRequest } from '@/types'
+import type { AuthResponse, LoginRequest, MFAChallenge, MFALoginRequest, RegisterRequest } from '@/types'
 
 export const useAuthStore = defineStore('auth', () => {
   // 状态
@@ -48,21 +48,26 @@ export const useAuthStore = defineStore('auth', () => {
     localStorage.removeItem('refresh_token')
   }
 
-  // 登录
-  const login = async (credentials: LoginRequest) => {
+  // 保存登录结果
+  const applyAuth = (data: AuthResponse) => {
+    user.value = data.user
+    accessToken.value = data.access_token
+    refreshToken.value = data.refresh_token
+    saveToStorage()
+    return data.user
+  }
+
+  // 登录，需要两步验证时返回 MFAChallenge，由页面继续完成第二步
+  const login = async (credentials: LoginRequest): Promise<User | MFAChallenge> => {
     isLoading.value = true
     error.value = null
 
     try {
       const response = await authApi.login(credentials)
-      const { user: userData, access_token, refresh_token } = response.data
-
-      user.value = userData
-      accessToken.value = access_token
-      refreshToken.value = refresh_token
-
-      saveToStorage()
-      return userData
+      if ('mfa_token' in response.data) {
+        return response.data
+      }
+      return applyAuth(response.data)
     } catch (err: any) {
       error.value = err.response?.data?.message || '登录失败'
       throw err
@@ -71,6 +76,28 @@ export const useAuthStore = defineStore('auth', () => {
     }
   }
 
+  // 统一身份认证回调后用票据登录，可能同样需要两步验证
+  const ssoLogin = async (ticket: string): Promise<User | MFAChallenge> => {
+    const response = await authApi.ssoExchange(ticket)
+    if ('mfa_token' in response.data) {
+      return response.data
+    }
+    return applyAuth(response.data)
+  }
+
+  // 登录第二步
+  const loginMFA = async (data: MFALoginRequest) => {
+    const response = await authApi.loginMFA(data)
+    return applyAuth(response.data)
+  }
+
+  // 首次绑定验证器并完成登录，返回恢复码
+  const activateMFA = async (data: MFALoginRequest) => {
+    const response = await authApi.activateMFA(data)
+    applyAuth(response.data)
+    return response.data.recovery_codes
+  }
+
   // 注册
   const register = async (data: RegisterRequest) => {
     isLoading.value = true
@@ -121,6 +148,9 @@ export const useAuthStore = defineStore('auth', () => {
     isAdmin,
     isUser,
     login,
+    loginMFA,
+    ssoLogin,
+    activateMFA,
     register,
     logout,
     updateUser,
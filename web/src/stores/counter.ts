@@ -56,7 +56,7 @@ export const usePackStore = defineStore('pack', () => {
       if (index !== -1 && response.data.pack) {
         packs.value[index] = response.data.pack
       }
-      return response.data.pack
+      return response.data
     } catch (err: any) {
       error.value = err.response?.data?.message || '取件失败'
       throw err
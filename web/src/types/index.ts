@@ -7,6 +7,10 @@ export interface User {
   address: string
   role: 'user' | 'admin'
   register_time?: string
+  totp_enabled?: boolean
+  deleted_at?: string | null
+  deletion_due_at?: string | null
+  has_password?: boolean
 }
 
 // 登录请求
@@ -32,8 +36,37 @@ export interface AuthResponse {
   refresh_token: string
 }
 
+// 登录第一步需要两步验证时的响应
+export interface MFAChallenge {
+  mfa_required: boolean
+  mfa_setup_required: boolean
+  mfa_token: string
+}
+
+// 登录第二步 / 首次绑定确认
+export interface MFALoginRequest {
+  mfa_token: string
+  code: string
+}
+
+// 绑定验证器所需信息，qr_code 为 PNG data URL
+export interface MFAEnrollment {
+  secret: string
+  otpauth_url: string
+  qr_code: string
+}
+
+// 统一身份认证提供方
+export interface SSOProvider {
+  name: string
+  type: 'oidc' | 'cas'
+  display_name: string
+}
+
 // 包裹状态类型
-export type PackStatus = 'pending' | 'checked_out' |'cancelled' | 'in_transit'
+export type PackStatus = 'pending' | 'checked_out' |'cancelled' | 'in_transit' | 'return_pending' | 'returned'
+// 包裹尺寸，用于计算保管费
+export type PackSize = 'small' | 'medium' | 'large'
 // 包裹信息
 export interface Pack {
   pack_id: number
@@ -41,6 +74,9 @@ export interface Pack {
   pack_status: PackStatus
   pickup_code?: string
   shelf_code?: number
+  carrier?: string
+  tracking_number?: string
+  size?: PackSize
   check_in_time?: string
   check_out_time?: string
   shipping_address?: string
@@ -50,6 +86,88 @@ export interface Pack {
   recipient_phone?: string
   created_at?: string
   updated_at?: string
+  overdue_level?: OverdueLevel | ''
+  overdue_notified_at?: string | null
+  return_manifest_id?: number | null
+  deleted_at?: string | null
+}
+
+// 逾期提醒级别
+export type OverdueLevel = 'remind' | 'warn' | 'return'
+
+// 逾期报表中的一行
+export interface OverduePack extends Pack {
+  age_hours: number
+  level: OverdueLevel
+}
+
+export interface OverdueQuery extends PageQuery {
+  level?: OverdueLevel
+  shelf_code?: number
+}
+
+// 保管费流水，金额单位为分，减免为负数
+export interface StorageFee {
+  entry_id: number
+  pack_id: number
+  user_id: number
+  kind: 'charge' | 'waiver'
+  amount: number
+  days: number
+  note: string
+  created_by: number
+  created_at: string
+}
+
+// 按当前策略计算的保管费
+export interface FeeQuote {
+  held_days: number
+  chargeable_days: number
+  multiplier: number
+  amount: number
+  exempt: boolean
+}
+
+// 包裹的保管费流水与待收金额，quote / waived 仅在启用保管费且包裹待取时返回
+export interface PackFees {
+  entries: StorageFee[]
+  balance: number
+  quote?: FeeQuote
+  waived?: boolean
+}
+
+// 退件交接单
+export interface ReturnManifest {
+  manifest_id: number
+  carrier: string
+  status: 'open' | 'handed_over'
+  created_by: number
+  created_at: string
+  handed_over_at: string | null
+  handed_over_by: number
+  courier_name: string
+  courier_phone: string
+  evidence_type: '' | 'signature' | 'photo'
+  evidence_content_type: string
+}
+
+// 创建交接单，pack_ids 为空时加入该快递公司全部待退回包裹
+export interface CreateManifestRequest {
+  carrier: string
+  pack_ids?: number[]
+}
+
+export interface ManifestListQuery extends PageQuery {
+  status?: ReturnManifest['status']
+  carrier?: string
+}
+
+// 登记交接，evidence 为签名或照片图片
+export interface HandoverRequest {
+  courier_name: string
+  courier_phone?: string
+  evidence_type: 'signature' | 'photo'
+  evidence: Blob
 }
 
 // 包裹入库请求
@@ -57,6 +175,7 @@ export interface PackCheckInRequest {
   pack_id: number
   user_id: number
   shelf_code: number
+  size?: PackSize
 }
 
 // 包裹出库请求
@@ -94,6 +213,130 @@ export interface UpdateUserInfoRequest {
   phone?: string
 }
 
+// 修改密码请求
+export interface ChangePasswordRequest {
+  user_id: number
+  old_password: string
+  new_password: string
+}
+
+// 找回密码：申请验证码
+export interface ForgotPasswordRequest {
+  student_id: string
+}
+
+// 找回密码：使用验证码重置
+export interface ResetPasswordRequest {
+  student_id: string
+  code: string
+  new_password: string
+}
+
+// 分页与排序参数，sort 为逗号分隔的字段，前缀 - 表示降序
+export interface PageQuery {
+  page?: number
+  page_size?: number
+  sort?: string
+}
+
+// 管理端包裹列表筛选
+export interface PackListQuery extends PageQuery {
+  status?: string
+  user_id?: number
+  carrier?: string
+  shelf_code?: number
+  pickup_code_prefix?: string
+  check_in_from?: string
+  check_in_to?: string
+  deleted?: boolean
+  overdue?: string // 逗号分隔的逾期级别
+}
+
+// 管理端用户列表筛选
+export interface UserListQuery extends PageQuery {
+  role?: string
+  deleted?: boolean
+  q?: string // 按姓名、学号或手机号模糊匹配
+}
+
+// 删除用户前的检查结果
+export interface UserDeletionCheck {
+  user: User
+  uncollected_packs: Pack[]
+  active_sessions: number
+}
+
+// 删除用户时未取包裹的处理方式，transfer 需要同时给出 transfer_to
+export interface DeleteUserParams {
+  parcels?: 'block' | 'transfer' | 'return'
+  transfer_to?: number
+}
+
+// 审计记录，before / after 只包含发生变化的字段
+export interface AuditLog {
+  audit_id: number
+  created_at: string
+  actor_type: 'user' | 'api_key' | 'anonymous' | 'system'
+  actor_id: number
+  action: string
+  target_type: string
+  target_id: string
+  before: Record<string, unknown> | null
+  after: Record<string, unknown> | null
+  ip: string
+  user_agent: string
+  request_id: string
+}
+
+export interface AuditLogQuery extends PageQuery {
+  actor_type?: AuditLog['actor_type']
+  actor_id?: number
+  action?: string // 逗号分隔
+  target_type?: string
+  target_id?: string
+  since?: string
+  until?: string
+}
+
+// 分页响应，列表字段名为 K
+export type PageResponse<K extends string, T> = {
+  [key in K]: T[]
+} & {
+  total: number
+  page: number
+  page_size: number
+}
+
+// 综合搜索结果，按类型分组，score 为相关度
+export interface SearchResult {
+  users: (User & { score: number })[]
+  packs: (Pack & { score: number })[]
+}
+
+// 站点设备使用的 API Key，明文只在创建时返回
+export type APIKeyScope = 'pack:read' | 'pack:checkin' | 'pack:checkout' | 'pack:status'
+
+export interface APIKey {
+  key_id: number
+  name: string
+  prefix: string
+  scopes: APIKeyScope[]
+  allowed_ips: string[] | null
+  created_by: number
+  created_at: string
+  expires_at: string | null
+  last_used_at: string | null
+  last_used_ip: string
+  revoked_at: string | null
+}
+
+export interface CreateAPIKeyRequest {
+  name: string
+  scopes: APIKeyScope[]
+  allowed_ips?: string[]
+  expires_at?: string
+}
+
 // API响应通用格式
 export interface ApiResponse<T = any> {
   message?: string
@@ -22,8 +22,7 @@ export default defineConfig({
     proxy: {
       '/api': {
         target: process.env.Server_IP,
-        changeOrigin: true,
-        rewrite: (path) => path.replace(/^\/api/, '')
+        changeOrigin: true
       }
     }
   }